@@ -20,6 +20,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,10 +49,33 @@ type Exporter struct {
 func InitExporter() (e *Exporter) {
 	var (
 		metricsPath = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
-		configPath  = kingpin.Flag("puppet.config-path", "Path to the puppet agent configuration file.").Default(puppetconfig.DefaultConfigPath).String()
-		lockPath    = kingpin.Flag("puppet.lock-path", "Path to the puppet agent disabled lock file.").Default(puppetdisabled.DefaultLockPath).String()
-		reportPath  = kingpin.Flag("puppet.report-path", "Path to the puppet agent last run report file.").Default(puppetreport.DefaultReportPath).String()
-		webConfig   = webflag.AddFlags(kingpin.CommandLine, ":9819")
+		configPath  = kingpin.Flag("puppet.config-path", "Path to the puppet agent configuration file. Detected from the installed Puppet layout when not given.").Default("").String()
+		lockPath    = kingpin.Flag("puppet.lock-path", "Path to the puppet agent disabled lock file. Derived from puppet.conf at startup when not given, so changing vardir or statedir takes a restart.").Default("").String()
+		reportPath  = kingpin.Flag("puppet.report-path", "Path to the puppet agent last run report file. Derived from puppet.conf at startup when not given, so changing vardir or statedir takes a restart.").Default("").String()
+		statePath   = kingpin.Flag("puppet.state-path", "Path to the file where the exporter keeps the history of past runs. Set it empty to disable.").Default(puppetreport.DefaultStatePath).String()
+
+		configSettings = kingpin.Flag("puppet.config-settings", "puppet.conf setting exported as puppet_config_setting, such as runinterval, repeated for each setting.").Strings()
+
+		failedResourcesLimit     = kingpin.Flag("puppet.failed-resources-limit", "Maximum number of failed resources of the last run exported by name.").Default(strconv.Itoa(puppetreport.DefaultFailedResourcesLimit)).Int()
+		dependencyFailuresLimit  = kingpin.Flag("puppet.dependency-failures-limit", "Maximum number of root dependency failures of the last run exported by name.").Default(strconv.Itoa(puppetreport.DefaultDependencyFailuresLimit)).Int()
+		failedFilesLimit         = kingpin.Flag("puppet.failed-files-limit", "Maximum number of manifest files the failed resources of the last run are counted by.").Default(strconv.Itoa(puppetreport.DefaultFailedFilesLimit)).Int()
+		packageChangesLimit      = kingpin.Flag("puppet.package-changes-limit", "Maximum number of package changes of the last run exported by name.").Default(strconv.Itoa(puppetreport.DefaultPackageChangesLimit)).Int()
+		serviceRestartsLimit     = kingpin.Flag("puppet.service-restarts-limit", "Maximum number of restarted services of the last run exported by name.").Default(strconv.Itoa(puppetreport.DefaultServiceRestartsLimit)).Int()
+		classResourcesLimit      = kingpin.Flag("puppet.class-resources-limit", "Maximum number of classes the failed and changed resources of the last run are attributed to. 0 disables the attribution.").Default("0").Int()
+		warningFingerprintsLimit = kingpin.Flag("puppet.warning-fingerprints-limit", "Maximum number of distinct warning message fingerprints of the last run exported. 0 disables the warning messages.").Default("0").Int()
+		slowestResourcesLimit    = kingpin.Flag("puppet.slowest-resources-limit", "Number of slowest resources of the last run exported with their evaluation time.").Default(strconv.Itoa(puppetreport.DefaultSlowestResourcesLimit)).Int()
+
+		resourceEvaluationBuckets      = kingpin.Flag("puppet.resource-evaluation-bucket", "Upper bound of a bucket of the resource evaluation time histogram, repeated for each bucket.").Default(formatFloats(puppetreport.DefaultResourceEvaluationBuckets)...).Float64List()
+		resourceEvaluationNativeFactor = kingpin.Flag("puppet.resource-evaluation-native-factor", "Bucket growth factor of the native resource evaluation time histogram, served to scrapers negotiating it. 1 or less disables it.").Default("1.1").Float64()
+
+		genericReportMetrics      = kingpin.Flag("puppet.generic-report-metrics", "Export every metric group of the last run report as puppet_last_run_report_metric.").Default("false").Bool()
+		genericReportMetricsAllow = kingpin.Flag("puppet.generic-report-metrics-allow", "Metric group exported by --puppet.generic-report-metrics, repeated for each group. All groups are exported when none is given.").Strings()
+		genericReportMetricsDeny  = kingpin.Flag("puppet.generic-report-metrics-deny", "Metric group never exported by --puppet.generic-report-metrics, repeated for each group.").Strings()
+
+		reportsDir    = kingpin.Flag("puppet.reports-dir", "Path to the puppet agent reports directory ($vardir/reports), kept when puppet.conf sets reports = store. Only the reports of the certname puppet.conf sets are read. Empty disables the run history metrics.").Default("").String()
+		reportsWindow = kingpin.Flag("puppet.reports-window", "Window of recent runs the run history metrics cover.").Default(puppetreport.DefaultArchiveWindow.String()).Duration()
+
+		webConfig = webflag.AddFlags(kingpin.CommandLine, ":9819")
 	)
 	promslogConfig := &promslog.Config{}
 	promslogflag.AddFlags(kingpin.CommandLine, promslogConfig)
@@ -70,14 +94,54 @@ func InitExporter() (e *Exporter) {
 		os.Exit(1)
 	}
 
+	if err := validateBuckets(*resourceEvaluationBuckets); err != nil {
+		logger.Error("Invalid --puppet.resource-evaluation-bucket", "err", err)
+		os.Exit(1)
+	}
+
+	layout := detectLayout(*configPath)
+	*configPath = layout.ConfigPath
+	logger.Info("Detected Puppet layout", "layout", layout.Name)
+	prometheus.MustRegister(newLayoutInfo(layout.Name))
+
+	*reportPath, *lockPath = resolvePaths(logger, layout, *reportPath, *lockPath)
+	logger.Info("Reading Puppet files", "config_path", *configPath, "report_path", *reportPath, "lock_path", *lockPath)
+	prometheus.MustRegister(newPathsInfo(*configPath, *reportPath, *lockPath))
+
 	prometheus.MustRegister(&puppetconfig.Collector{
 		Logger:     logger,
 		ConfigPath: *configPath,
+		Settings:   *configSettings,
 	})
 	prometheus.MustRegister(&puppetreport.Collector{
-		Logger:     logger,
-		ReportPath: *reportPath,
+		Logger:                         logger,
+		ReportPath:                     *reportPath,
+		ConfigPath:                     *configPath,
+		StatePath:                      *statePath,
+		FailedResourcesLimit:           *failedResourcesLimit,
+		DependencyFailuresLimit:        *dependencyFailuresLimit,
+		FailedFilesLimit:               *failedFilesLimit,
+		SlowestResourcesLimit:          *slowestResourcesLimit,
+		ClassResourcesLimit:            *classResourcesLimit,
+		WarningFingerprintsLimit:       *warningFingerprintsLimit,
+		PackageChangesLimit:            *packageChangesLimit,
+		ServiceRestartsLimit:           *serviceRestartsLimit,
+		ResourceEvaluationBuckets:      *resourceEvaluationBuckets,
+		ResourceEvaluationNativeFactor: *resourceEvaluationNativeFactor,
+		GenericReportMetrics:           *genericReportMetrics,
+		GenericReportMetricsAllow:      *genericReportMetricsAllow,
+		GenericReportMetricsDeny:       *genericReportMetricsDeny,
 	})
+	if *reportsDir != "" {
+		certname := resolveCertname(logger, layout)
+		logger.Info("Reading Puppet reports archive", "reports_dir", *reportsDir, "certname", certname)
+		prometheus.MustRegister(&puppetreport.ArchiveCollector{
+			Logger:     logger,
+			ReportsDir: *reportsDir,
+			Certname:   certname,
+			Window:     *reportsWindow,
+		})
+	}
 	prometheus.MustRegister(&puppetdisabled.Collector{
 		Logger:   logger,
 		LockPath: *lockPath,
@@ -127,6 +191,25 @@ func validateTelemetryPath(path string) error {
 	return nil
 }
 
+// validateBuckets rejects histogram buckets that are not in increasing order,
+// which the histogram would otherwise panic on at the first scrape.
+func validateBuckets(buckets []float64) error {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("bucket %v must be greater than the previous bucket %v", buckets[i], buckets[i-1])
+		}
+	}
+	return nil
+}
+
+func formatFloats(values []float64) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return result
+}
+
 // Serve Start the http web server
 func (e *Exporter) Serve() {
 	if err := web.ListenAndServe(e.server, e.webConfig, e.Logger); err != nil {
@@ -37,3 +37,23 @@ func TestValidateTelemetryPath(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		buckets []float64
+		wantErr bool
+	}{
+		{buckets: []float64{0.1, 1, 10}},
+		{buckets: nil},
+		{buckets: []float64{1, 0.1}, wantErr: true},
+		{buckets: []float64{1, 1}, wantErr: true},
+	} {
+		err := validateBuckets(tc.buckets)
+		if tc.wantErr && err == nil {
+			t.Errorf("validateBuckets(%v) = nil, want an error", tc.buckets)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("validateBuckets(%v) = %v, want nil", tc.buckets, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/fgouteroux/puppet-agent-exporter/puppetconfig"
+)
+
+// detectLayout returns the layout of the Puppet installation, probing the
+// known ones unless configPath is given.
+func detectLayout(configPath string) puppetconfig.Layout {
+	if configPath != "" {
+		return puppetconfig.LayoutFor(configPath)
+	}
+	return puppetconfig.DetectLayout()
+}
+
+// resolvePaths returns the report and lock paths, taking those not given by a
+// flag from the puppet.conf of layout, where the agent may have moved them
+// through vardir, statedir, lastrunreport or agent_disabled_lockfile. The
+// defaults under the vardir of layout are used when puppet.conf cannot be read.
+func resolvePaths(logger *slog.Logger, layout puppetconfig.Layout, reportPath, lockPath string) (string, string) {
+	if reportPath != "" && lockPath != "" {
+		return reportPath, lockPath
+	}
+
+	config, err := puppetconfig.Load(layout.ConfigPath)
+	if err != nil {
+		logger.Warn("Failed to read puppet config file, using the default paths", "err", err)
+		if reportPath == "" {
+			reportPath = filepath.Join(layout.Vardir, "state", "last_run_report.yaml")
+		}
+		if lockPath == "" {
+			lockPath = filepath.Join(layout.Vardir, "state", "agent_disabled.lock")
+		}
+		return reportPath, lockPath
+	}
+
+	if reportPath == "" {
+		reportPath = config.Value("lastrunreport")
+	}
+	if lockPath == "" {
+		lockPath = config.Value("agent_disabled_lockfile")
+	}
+	return reportPath, lockPath
+}
+
+// resolveCertname returns the certname of the node from the puppet.conf of
+// layout, or the default certname of the host when it cannot be read.
+func resolveCertname(logger *slog.Logger, layout puppetconfig.Layout) string {
+	config, err := puppetconfig.Load(layout.ConfigPath)
+	if err != nil {
+		logger.Warn("Failed to read puppet config file, using the default certname", "err", err)
+		return puppetconfig.DefaultCertname()
+	}
+	return config.Value("certname")
+}
+
+// newPathsInfo returns a metric holding the paths of the files the exporter
+// reads, so that a scrape error can be told apart from a misplaced file.
+func newPathsInfo(configPath, reportPath, lockPath string) prometheus.Gauge {
+	info := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "puppet_agent_exporter_paths_info",
+		Help: "Paths of the Puppet files the exporter reads.",
+		ConstLabels: prometheus.Labels{
+			"config_path": configPath,
+			"report_path": reportPath,
+			"lock_path":   lockPath,
+		},
+	})
+	info.Set(1)
+	return info
+}
+
+// newLayoutInfo returns a metric holding the layout of the Puppet
+// installation the exporter found.
+func newLayoutInfo(layout string) prometheus.Gauge {
+	info := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "puppet_agent_layout_info",
+		Help:        "Layout of the Puppet installation: aio, openvox, debian, user or custom.",
+		ConstLabels: prometheus.Labels{"layout": layout},
+	})
+	info.Set(1)
+	return info
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+
+	"github.com/fgouteroux/puppet-agent-exporter/puppetconfig"
+)
+
+func TestResolvePaths(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "puppet.conf")
+	config := "[main]\nvardir = /var/lib/puppet\n\n[agent]\nlastrunreport = /srv/puppet/last_run_report.yaml\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	layout := puppetconfig.Layout{Name: "custom", ConfigPath: configPath, Vardir: "/opt/puppetlabs/puppet/cache"}
+	// When puppet.conf cannot be read, the paths follow the vardir of the
+	// layout it was looked for in.
+	missing := puppetconfig.Layout{Name: "debian", ConfigPath: filepath.Join(t.TempDir(), "absent.conf"), Vardir: "/var/cache/puppet"}
+
+	for _, tc := range []struct {
+		name                 string
+		layout               puppetconfig.Layout
+		reportPath, lockPath string
+		wantReport, wantLock string
+	}{
+		{
+			name:       "derived from puppet.conf",
+			layout:     layout,
+			wantReport: "/srv/puppet/last_run_report.yaml",
+			wantLock:   "/var/lib/puppet/state/agent_disabled.lock",
+		},
+		{
+			name:       "flags win",
+			layout:     layout,
+			reportPath: "/tmp/report.yaml",
+			lockPath:   "/tmp/agent_disabled.lock",
+			wantReport: "/tmp/report.yaml",
+			wantLock:   "/tmp/agent_disabled.lock",
+		},
+		{
+			name:       "unreadable puppet.conf",
+			layout:     missing,
+			lockPath:   "/tmp/agent_disabled.lock",
+			wantReport: filepath.Join("/var/cache/puppet", "state", "last_run_report.yaml"),
+			wantLock:   "/tmp/agent_disabled.lock",
+		},
+		{
+			name:       "defaults under the layout vardir",
+			layout:     missing,
+			wantReport: filepath.Join("/var/cache/puppet", "state", "last_run_report.yaml"),
+			wantLock:   filepath.Join("/var/cache/puppet", "state", "agent_disabled.lock"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report, lock := resolvePaths(promslog.NewNopLogger(), tc.layout, tc.reportPath, tc.lockPath)
+			if report != tc.wantReport || lock != tc.wantLock {
+				t.Errorf("resolvePaths() = %q, %q, want %q, %q", report, lock, tc.wantReport, tc.wantLock)
+			}
+		})
+	}
+}
+
+func TestResolveCertname(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "puppet.conf")
+	if err := os.WriteFile(configPath, []byte("[agent]\ncertname = node1.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		configPath string
+		want       string
+	}{
+		{name: "set in puppet.conf", configPath: configPath, want: "node1.example.com"},
+		{name: "unreadable puppet.conf", configPath: filepath.Join(t.TempDir(), "absent.conf"), want: puppetconfig.DefaultCertname()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resolveCertname(promslog.NewNopLogger(), puppetconfig.Layout{ConfigPath: tc.configPath}); got != tc.want {
+				t.Errorf("resolveCertname() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
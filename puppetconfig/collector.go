@@ -16,9 +16,10 @@ package puppetconfig
 
 import (
 	"log/slog"
+	"slices"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"gopkg.in/ini.v1"
 )
 
 var (
@@ -28,6 +29,18 @@ var (
 		[]string{"server", "environment"},
 		nil,
 	)
+	settingDesc = prometheus.NewDesc(
+		"puppet_config_setting",
+		"Puppet configuration setting, with its value.",
+		[]string{"key", "value"},
+		nil,
+	)
+	settingValueDesc = prometheus.NewDesc(
+		"puppet_config_setting_value",
+		"Value of a numeric Puppet configuration setting, in seconds for durations.",
+		[]string{"key"},
+		nil,
+	)
 	scrapeErrorDesc = prometheus.NewDesc(
 		"puppet_config_scrape_error",
 		"1 if there was an error opening or reading a file, 0 otherwise",
@@ -36,42 +49,88 @@ var (
 	)
 )
 
-// sections are searched in the order Puppet itself resolves agent settings:
-// the agent-specific section wins over the global one.
-var sections = []string{"agent", "main"}
-
 type Collector struct {
 	Logger     *slog.Logger
 	ConfigPath string
+	// Settings are the keys of the settings exported as puppet_config_setting,
+	// and as puppet_config_setting_value when they are numbers or durations.
+	// Settings puppet.conf does not set are exported with their default when
+	// the exporter knows it, such as certname or vardir, and left out
+	// otherwise.
+	Settings []string
 }
 
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- configDesc
+	ch <- settingDesc
+	ch <- settingValueDesc
 	ch <- scrapeErrorDesc
 }
 
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	var errVal float64
-	config, err := ini.Load(c.configPath())
+	config, err := Load(c.configPath())
 	if err != nil {
 		c.Logger.Error("Failed to open puppet config file", "err", err)
 		errVal = 1.0
 	} else {
-		server := setting(config, "server")
-		environment := setting(config, "environment")
+		server := config.Setting("server")
+		environment := config.Setting("environment")
 		ch <- prometheus.MustNewConstMetric(configDesc, prometheus.GaugeValue, 1, server, environment)
+		c.collectSettings(ch, config)
 	}
 
 	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, errVal)
 }
 
-// setting returns the value of an agent setting, honouring the section
-// precedence Puppet applies when the agent reads its own configuration.
-func setting(config *ini.File, key string) string {
-	for _, section := range sections {
-		if value := config.Section(section).Key(key).String(); value != "" {
-			return value
+// collectSettings exports the settings of the allowlist that have a value. A
+// setting listed twice is exported once.
+func (c *Collector) collectSettings(ch chan<- prometheus.Metric, config *Config) {
+	for i, key := range c.Settings {
+		if slices.Contains(c.Settings[:i], key) {
+			continue
+		}
+		value := config.Value(key)
+		if value == "" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(settingDesc, prometheus.GaugeValue, 1, key, value)
+		if number, ok := numericValue(key, value); ok {
+			ch <- prometheus.MustNewConstMetric(settingValueDesc, prometheus.GaugeValue, number, key)
 		}
 	}
-	return ""
+}
+
+// durationSettings are the settings Puppet parses as durations, so that a
+// value such as 30m is only read as one for them.
+var durationSettings = []string{
+	"runinterval",
+	"runtimeout",
+	"splaylimit",
+	"filetimeout",
+	"environment_timeout",
+	"http_connect_timeout",
+	"http_read_timeout",
+	"http_keepalive_timeout",
+	"ca_ttl",
+	"certificate_expire_warning",
+	"waitforcert",
+	"maxwaitforcert",
+	"waitforlock",
+	"maxwaitforlock",
+}
+
+// numericValue returns the value of a number setting, or of a duration setting
+// in seconds.
+func numericValue(key, value string) (float64, bool) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number, true
+	}
+	if !slices.Contains(durationSettings, key) {
+		return 0, false
+	}
+	if duration, err := ParseDuration(value); err == nil {
+		return duration.Seconds(), true
+	}
+	return 0, false
 }
@@ -75,6 +75,18 @@ puppet_config{environment="production",server="puppet.example.com"} 1
 # HELP puppet_config_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
 # TYPE puppet_config_scrape_error gauge
 puppet_config_scrape_error 0
+`,
+		},
+		{
+			name:   "server_list fallback",
+			config: "[agent]\nserver_list = puppet1.example.com:8140,puppet2.example.com:8140\nenvironment = production\n",
+			expected: `
+# HELP puppet_config Puppet configuration.
+# TYPE puppet_config gauge
+puppet_config{environment="production",server="puppet1.example.com"} 1
+# HELP puppet_config_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
+# TYPE puppet_config_scrape_error gauge
+puppet_config_scrape_error 0
 `,
 		},
 		{
@@ -99,6 +111,33 @@ puppet_config_scrape_error 0
 	}
 }
 
+func TestCollectSettings(t *testing.T) {
+	c := &Collector{
+		Logger:     promslog.NewNopLogger(),
+		ConfigPath: writeConfig(t, "[main]\nserver = puppet.example.com\nnoop = true\nruninterval = 1800\n\n[agent]\nruninterval = 4h\nsplay = true\nsplaylimit = 600\ntags = 3d\n"),
+		Settings:   []string{"runinterval", "splaylimit", "noop", "certname", "ca_port", "tags", "noop"},
+	}
+
+	// certname is not set but has a default, ca_port has neither. tags is not
+	// a duration setting, so 3d is not read as one.
+	expected := `
+# HELP puppet_config_setting Puppet configuration setting, with its value.
+# TYPE puppet_config_setting gauge
+puppet_config_setting{key="certname",value="` + hostFQDN() + `"} 1
+puppet_config_setting{key="noop",value="true"} 1
+puppet_config_setting{key="runinterval",value="4h"} 1
+puppet_config_setting{key="splaylimit",value="600"} 1
+puppet_config_setting{key="tags",value="3d"} 1
+# HELP puppet_config_setting_value Value of a numeric Puppet configuration setting, in seconds for durations.
+# TYPE puppet_config_setting_value gauge
+puppet_config_setting_value{key="runinterval"} 14400
+puppet_config_setting_value{key="splaylimit"} 600
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_config_setting", "puppet_config_setting_value"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestCollectMissingFile(t *testing.T) {
 	c := &Collector{Logger: promslog.NewNopLogger(), ConfigPath: filepath.Join(t.TempDir(), "absent.conf")}
 
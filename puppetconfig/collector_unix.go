@@ -16,12 +16,64 @@
 
 package puppetconfig
 
+import "os"
+
 // DefaultConfigPath is the default location of the puppet agent configuration file on unix.
 const DefaultConfigPath = "/etc/puppetlabs/puppet/puppet.conf"
 
+const (
+	// defaultVardir and defaultCodedir are the vardir and codedir Puppet
+	// uses on unix when puppet.conf does not set them.
+	defaultVardir  = "/opt/puppetlabs/puppet/cache"
+	defaultCodedir = "/etc/puppetlabs/code"
+)
+
+// openvoxMarkers are the files the OpenVox agent packages leave behind and the
+// Puppet ones do not, as both install to the same locations. dpkg keeps the
+// file list of the openvox-agent package, and its documentation directory is
+// named after it. OpenVox installed from RPM packages is reported as aio.
+var openvoxMarkers = []string{
+	"/var/lib/dpkg/info/openvox-agent.list",
+	"/usr/share/doc/openvox-agent",
+}
+
+// layoutCandidates are the layouts probed on unix, in order: the AIO packages
+// of OpenVox and Puppet, the Debian and Ubuntu packages, which moved their
+// vardir from /var/lib/puppet to /var/cache/puppet, and an agent running as a
+// regular user under ~/.puppetlabs. The user layout is probed in the home
+// directory of the user the exporter runs as, so an exporter running as
+// another user than the agent needs --puppet.config-path to find it.
+func layoutCandidates() []layoutCandidate {
+	candidates := []layoutCandidate{
+		{
+			name:       "openvox",
+			configPath: DefaultConfigPath,
+			vardirs:    []string{defaultVardir},
+			codedir:    defaultCodedir,
+			markers:    openvoxMarkers,
+		},
+		{
+			name:       "aio",
+			configPath: DefaultConfigPath,
+			vardirs:    []string{defaultVardir},
+			codedir:    defaultCodedir,
+		},
+		{
+			name:       "debian",
+			configPath: "/etc/puppet/puppet.conf",
+			vardirs:    []string{"/var/cache/puppet", "/var/lib/puppet"},
+			codedir:    "/etc/puppet/code",
+		},
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, userLayoutCandidate(home))
+	}
+	return candidates
+}
+
 func (c *Collector) configPath() string {
 	if c.ConfigPath != "" {
 		return c.ConfigPath
 	}
-	return DefaultConfigPath
+	return DetectLayout().ConfigPath
 }
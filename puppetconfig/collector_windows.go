@@ -19,9 +19,29 @@ package puppetconfig
 // DefaultConfigPath is the default location of the puppet agent configuration file on windows.
 const DefaultConfigPath = "C:/ProgramData/PuppetLabs/puppet/etc/puppet.conf"
 
+const (
+	// defaultVardir and defaultCodedir are the vardir and codedir Puppet
+	// uses on windows when puppet.conf does not set them.
+	defaultVardir  = "C:/ProgramData/PuppetLabs/puppet/cache"
+	defaultCodedir = "C:/ProgramData/PuppetLabs/code"
+)
+
+// layoutCandidates are the layouts probed on windows, where Puppet and
+// OpenVox install in the same place.
+func layoutCandidates() []layoutCandidate {
+	return []layoutCandidate{
+		{
+			name:       "aio",
+			configPath: DefaultConfigPath,
+			vardirs:    []string{defaultVardir},
+			codedir:    defaultCodedir,
+		},
+	}
+}
+
 func (c *Collector) configPath() string {
 	if c.ConfigPath != "" {
 		return c.ConfigPath
 	}
-	return DefaultConfigPath
+	return DetectLayout().ConfigPath
 }
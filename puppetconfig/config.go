@@ -0,0 +1,291 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// DefaultEnvironment is the environment an agent requests when puppet.conf
+// does not set one.
+const DefaultEnvironment = "production"
+
+// RunMode is the Puppet application a configuration is resolved for. It
+// decides which puppet.conf sections apply.
+type RunMode string
+
+const (
+	RunModeAgent  RunMode = "agent"
+	RunModeUser   RunMode = "user"
+	RunModeServer RunMode = "server"
+)
+
+// runModeSections are the puppet.conf sections each run mode reads, in the
+// order Puppet searches them: the run mode section wins over [main]. [master]
+// is the name [server] had before Puppet 6.
+var runModeSections = map[RunMode][]string{
+	RunModeAgent:  {"agent", "main"},
+	RunModeUser:   {"user", "main"},
+	RunModeServer: {"server", "master", "main"},
+}
+
+// environmentSettings are the settings the environment.conf of the
+// environment in use overrides.
+var environmentSettings = []string{
+	"modulepath",
+	"manifest",
+	"config_version",
+	"environment_timeout",
+	"static_catalogs",
+	"rich_data",
+}
+
+// variablePattern matches the $name and ${name} references to other settings
+// Puppet interpolates in setting values.
+var variablePattern = regexp.MustCompile(`\$(\w+)|\$\{(\w+)\}`)
+
+// Config is a parsed puppet.conf, resolved for a run mode.
+type Config struct {
+	file     *ini.File
+	confdir  string
+	sections []string
+	layout   Layout
+
+	// environmentDir and environment are the directory and environment.conf
+	// of the environment in use, when there is one.
+	environmentDir string
+	environment    *ini.File
+}
+
+// Load parses the puppet.conf at path, resolved for the agent.
+func Load(path string) (*Config, error) {
+	return LoadRunMode(path, RunModeAgent)
+}
+
+// LoadRunMode parses the puppet.conf at path, resolved for mode, along with
+// the environment.conf of the environment it selects if there is one.
+func LoadRunMode(path string, mode RunMode) (*Config, error) {
+	sections, ok := runModeSections[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown run mode %q", mode)
+	}
+	file, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{file: file, confdir: filepath.Dir(path), sections: sections, layout: LayoutFor(path)}
+	c.loadEnvironment()
+	return c, nil
+}
+
+// loadEnvironment loads the environment.conf of the environment in use from
+// the first directory of environmentpath holding that environment. An
+// environment.conf that cannot be read is skipped, leaving the settings it
+// may override to puppet.conf, rather than failing the whole configuration.
+func (c *Config) loadEnvironment() {
+	environment := c.Setting("environment")
+	if environment == "" {
+		environment = DefaultEnvironment
+	}
+	environmentPath := c.Setting("environmentpath")
+	if environmentPath == "" {
+		environmentPath = "$codedir/environments"
+	}
+	for _, dir := range filepath.SplitList(c.interpolate(environmentPath, make(map[string]bool))) {
+		environmentDir := filepath.Join(dir, environment)
+		file, err := ini.Load(filepath.Join(environmentDir, "environment.conf"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		c.environmentDir = environmentDir
+		c.environment = file
+		return
+	}
+}
+
+// Setting returns the value of a setting the way Puppet resolves it for the
+// run mode: environment.conf first for the settings it may override, then the
+// run mode section, then [main], with references to other settings
+// interpolated. server falls back to the first server of server_list. It
+// returns "" for a setting that is not set, see Value for its default.
+func (c *Config) Setting(key string) string {
+	return c.resolve(key, make(map[string]bool))
+}
+
+// Value returns the value of a setting like Setting, or its Puppet default
+// when it is not set, for the settings the exporter knows the default of. It
+// is the value a $key reference to the setting interpolates to.
+func (c *Config) Value(key string) string {
+	if value := c.Setting(key); value != "" {
+		return value
+	}
+	return c.interpolate(c.defaultValue(key), make(map[string]bool))
+}
+
+func (c *Config) resolve(key string, resolving map[string]bool) string {
+	if resolving[key] {
+		return ""
+	}
+	resolving[key] = true
+	defer delete(resolving, key)
+
+	value, fromEnvironment := c.lookup(key)
+	if value == "" && key == "server" {
+		value = firstServer(c.resolve("server_list", resolving))
+	}
+	value = c.interpolate(value, resolving)
+	if fromEnvironment {
+		value = c.environmentRelative(key, value)
+	}
+	return value
+}
+
+// interpolate replaces the references to other settings in value by their
+// values, or their defaults when they are not set. References to unknown
+// settings are kept as they are.
+func (c *Config) interpolate(value string, resolving map[string]bool) string {
+	return variablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		match := variablePattern.FindStringSubmatch(reference)
+		name := match[1] + match[2]
+		if resolved := c.resolve(name, resolving); resolved != "" {
+			return resolved
+		}
+		if resolved := c.interpolate(c.defaultValue(name), resolving); resolved != "" {
+			return resolved
+		}
+		return reference
+	})
+}
+
+// lookup returns the raw value of a setting, and whether it comes from
+// environment.conf.
+func (c *Config) lookup(key string) (string, bool) {
+	if c.environment != nil && slices.Contains(environmentSettings, key) {
+		if value := c.environment.Section("").Key(key).String(); value != "" {
+			return value, true
+		}
+	}
+	for _, section := range c.sections {
+		if value := c.file.Section(section).Key(key).String(); value != "" {
+			return value, false
+		}
+	}
+	return "", false
+}
+
+// defaultValue returns the default of the settings commonly referenced from
+// other settings, and of the paths the exporter reads, used when puppet.conf
+// does not set them. confdir is the directory of puppet.conf itself, and the
+// vardir and codedir defaults are those of its layout.
+func (c *Config) defaultValue(key string) string {
+	switch key {
+	case "statedir":
+		return "$vardir/state"
+	case "lastrunreport":
+		return "$statedir/last_run_report.yaml"
+	case "agent_disabled_lockfile":
+		return "$statedir/agent_disabled.lock"
+	case "confdir":
+		return c.confdir
+	case "vardir":
+		return c.layout.Vardir
+	case "codedir":
+		return c.layout.Codedir
+	case "environment":
+		return DefaultEnvironment
+	case "certname":
+		return hostFQDN()
+	}
+	return ""
+}
+
+// DefaultCertname returns the certname of the host when puppet.conf does not
+// set one.
+func DefaultCertname() string {
+	return hostFQDN()
+}
+
+// hostFQDN returns the lowercased fully qualified domain name of the host,
+// Puppet's default certname. A hostname without a domain is qualified through
+// the resolver, and kept as it is when that fails. It is resolved once, as
+// defaultValue runs on every scrape.
+var hostFQDN = sync.OnceValue(func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	if !strings.Contains(hostname, ".") {
+		ctx, cancel := context.WithTimeout(context.Background(), fqdnLookupTimeout)
+		defer cancel()
+		if cname, err := net.DefaultResolver.LookupCNAME(ctx, hostname); err == nil && strings.Contains(strings.TrimSuffix(cname, "."), ".") {
+			hostname = strings.TrimSuffix(cname, ".")
+		}
+	}
+	return strings.ToLower(hostname)
+})
+
+// fqdnLookupTimeout bounds the lookup qualifying the hostname.
+const fqdnLookupTimeout = 2 * time.Second
+
+// environmentRelative resolves the relative paths of the modulepath and
+// manifest settings of environment.conf against the environment directory,
+// as Puppet does.
+func (c *Config) environmentRelative(key, value string) string {
+	switch key {
+	case "manifest":
+		return joinRelative(c.environmentDir, value)
+	case "modulepath":
+		paths := filepath.SplitList(value)
+		for i, path := range paths {
+			paths[i] = joinRelative(c.environmentDir, path)
+		}
+		return strings.Join(paths, string(os.PathListSeparator))
+	}
+	return value
+}
+
+func joinRelative(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// firstServer returns the host of the first entry of a server_list setting
+// such as "puppet1.example.com:8140,puppet2.example.com".
+func firstServer(serverList string) string {
+	first, _, _ := strings.Cut(serverList, ",")
+	first = strings.TrimSpace(first)
+	if host, _, err := net.SplitHostPort(first); err == nil {
+		return host
+	}
+	return first
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunModeSections(t *testing.T) {
+	path := writeConfig(t, `[main]
+server = main.example.com
+ca_server = ca.example.com
+
+[agent]
+server = agent.example.com
+
+[user]
+server = user.example.com
+
+[master]
+server = master.example.com
+`)
+
+	for _, tc := range []struct {
+		mode   RunMode
+		key    string
+		want   string
+		config string
+	}{
+		{mode: RunModeAgent, key: "server", want: "agent.example.com"},
+		{mode: RunModeUser, key: "server", want: "user.example.com"},
+		// [master] is still read by servers that predate [server].
+		{mode: RunModeServer, key: "server", want: "master.example.com"},
+		{mode: RunModeServer, key: "server", want: "server.example.com", config: "[master]\nserver = master.example.com\n\n[server]\nserver = server.example.com\n"},
+		{mode: RunModeAgent, key: "ca_server", want: "ca.example.com"},
+		{mode: RunModeAgent, key: "ca_port", want: ""},
+	} {
+		configPath := path
+		if tc.config != "" {
+			configPath = writeConfig(t, tc.config)
+		}
+		config, err := LoadRunMode(configPath, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.Setting(tc.key); got != tc.want {
+			t.Errorf("%s: Setting(%q) = %q, want %q", tc.mode, tc.key, got, tc.want)
+		}
+	}
+
+	if _, err := LoadRunMode(path, "apply"); err == nil {
+		t.Error("LoadRunMode(apply) = nil error, want an error")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	path := writeConfig(t, `[main]
+vardir = /var/lib/puppet
+certname = node1.example.com
+ssldir = $confdir/ssl
+hostcert = ${ssldir}/certs/$certname.pem
+basemodulepath = $codedir/modules
+external = $unknown/facts
+loop = $other
+other = $loop
+
+[agent]
+statedir = $vardir/state
+`)
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confdir := filepath.Dir(path)
+	for _, tc := range []struct {
+		key  string
+		want string
+	}{
+		{key: "statedir", want: "/var/lib/puppet/state"},
+		{key: "ssldir", want: confdir + "/ssl"},
+		{key: "hostcert", want: confdir + "/ssl/certs/node1.example.com.pem"},
+		// codedir is not set, so its default applies.
+		{key: "basemodulepath", want: defaultCodedir + "/modules"},
+		{key: "external", want: "$unknown/facts"},
+		// A reference cycle is left unresolved rather than followed forever.
+		{key: "loop", want: "$loop"},
+	} {
+		if got := config.Setting(tc.key); got != tc.want {
+			t.Errorf("Setting(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestServerList(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		want   string
+	}{
+		{config: "[agent]\nserver_list = puppet1.example.com:8140, puppet2.example.com\n", want: "puppet1.example.com"},
+		{config: "[agent]\nserver_list = puppet1.example.com\n", want: "puppet1.example.com"},
+		{config: "[agent]\nserver_list = [2001:db8::1]:8140\n", want: "2001:db8::1"},
+		{config: "[main]\nserver = puppet.example.com\n\n[agent]\nserver_list = puppet1.example.com\n", want: "puppet.example.com"},
+		{config: "[main]\n", want: ""},
+	} {
+		config, err := Load(writeConfig(t, tc.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.Setting("server"); got != tc.want {
+			t.Errorf("Setting(server) with %q = %q, want %q", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestEnvironmentConf(t *testing.T) {
+	dir := t.TempDir()
+	environments := filepath.Join(dir, "environments")
+	staging := filepath.Join(environments, "staging")
+	if err := os.MkdirAll(staging, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	environmentConf := "modulepath = site:modules:$basemodulepath\nmanifest = manifests/site.pp\nconfig_version = /usr/local/bin/config_version\nserver = ignored.example.com\n"
+	if err := os.WriteFile(filepath.Join(staging, "environment.conf"), []byte(environmentConf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(writeConfig(t, `[main]
+environmentpath = `+filepath.Join(dir, "missing")+string(os.PathListSeparator)+environments+`
+basemodulepath = /srv/modules
+modulepath = /srv/other
+server = puppet.example.com
+
+[agent]
+environment = staging
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key  string
+		want string
+	}{
+		{key: "modulepath", want: strings.Join([]string{filepath.Join(staging, "site"), filepath.Join(staging, "modules"), "/srv/modules"}, string(os.PathListSeparator))},
+		{key: "manifest", want: filepath.Join(staging, "manifests/site.pp")},
+		{key: "config_version", want: "/usr/local/bin/config_version"},
+		// Only the settings environment.conf may set are taken from it.
+		{key: "server", want: "puppet.example.com"},
+	} {
+		if got := config.Setting(tc.key); got != tc.want {
+			t.Errorf("Setting(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+// An environment.conf that cannot be read leaves puppet.conf in effect.
+func TestUnreadableEnvironmentConf(t *testing.T) {
+	environments := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(environments, "production", "environment.conf"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(writeConfig(t, "[main]\nenvironmentpath = "+environments+"\nmanifest = /srv/site.pp\n"))
+	if err != nil {
+		t.Fatalf("Load() = %v, want no error", err)
+	}
+	if got, want := config.Setting("manifest"), "/srv/site.pp"; got != want {
+		t.Errorf("Setting(manifest) = %q, want %q", got, want)
+	}
+}
+
+func TestValue(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		key    string
+		want   string
+	}{
+		{config: "[main]\n", key: "lastrunreport", want: defaultVardir + "/state/last_run_report.yaml"},
+		{config: "[main]\nvardir = /var/lib/puppet\n", key: "lastrunreport", want: "/var/lib/puppet/state/last_run_report.yaml"},
+		{config: "[main]\nvardir = /var/lib/puppet\n\n[agent]\nstatedir = /srv/puppet/state\n", key: "agent_disabled_lockfile", want: "/srv/puppet/state/agent_disabled.lock"},
+		{config: "[agent]\nlastrunreport = /tmp/report.yaml\n", key: "lastrunreport", want: "/tmp/report.yaml"},
+		{config: "[main]\n", key: "server", want: ""},
+		{config: "[main]\n", key: "certname", want: hostFQDN()},
+	} {
+		config, err := Load(writeConfig(t, tc.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.Value(tc.key); got != tc.want {
+			t.Errorf("Value(%q) with %q = %q, want %q", tc.key, tc.config, got, tc.want)
+		}
+	}
+}
+
+// A setting referenced from another one interpolates to the value Value
+// returns for it, default or not.
+func TestValueMatchesInterpolation(t *testing.T) {
+	config, err := Load(writeConfig(t, "[main]\nvardir = /var/lib/puppet\n\n[agent]\nreference = $value\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"vardir", "statedir", "codedir", "confdir", "environment", "certname"} {
+		config.file.Section("agent").Key("reference").SetValue("$" + key)
+		if got, want := config.Setting("reference"), config.Value(key); got != want {
+			t.Errorf("$%s = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var durationPattern = regexp.MustCompile(`^(\d+)([smhdy]?)$`)
+
+var durationUnits = map[string]time.Duration{
+	"":  time.Second,
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration setting such as runinterval the way Puppet
+// does: a number of seconds, or a number followed by one of the s, m, h, d or
+// y units. Durations that do not fit in a time.Duration are rejected.
+func ParseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	unit := durationUnits[match[2]]
+	if n > math.MaxInt64/int64(unit) {
+		return 0, fmt.Errorf("invalid duration %q: out of range", value)
+	}
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "1800", want: 30 * time.Minute},
+		{value: "30m", want: 30 * time.Minute},
+		{value: "45s", want: 45 * time.Second},
+		{value: "4h", want: 4 * time.Hour},
+		{value: "2d", want: 48 * time.Hour},
+		{value: "1y", want: 365 * 24 * time.Hour},
+		{value: "", wantErr: true},
+		{value: "1.5h", wantErr: true},
+		{value: "30 m", wantErr: true},
+		{value: "-5m", wantErr: true},
+		{value: "9223372036s", want: 9223372036 * time.Second},
+		{value: "9223372037s", wantErr: true},
+		{value: "300y", wantErr: true},
+		{value: "99999999999999999999", wantErr: true},
+	} {
+		got, err := ParseDuration(tc.value)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want an error", tc.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tc.value, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Layout is where a Puppet installation keeps its configuration and data.
+type Layout struct {
+	// Name identifies the layout: aio for the Puppet packages, openvox for
+	// the OpenVox packages, which share their locations and are told apart
+	// by a marker file, debian for the Debian and Ubuntu packages, user for
+	// an agent running as a regular user, and custom for a puppet.conf found
+	// anywhere else.
+	Name       string
+	ConfigPath string
+	Vardir     string
+	Codedir    string
+}
+
+// layoutCandidate is a layout the exporter knows of, with the vardirs its
+// packages have used over time, most recent first. A candidate with markers
+// only matches when one of those files exists, which tells apart packages
+// sharing the same locations.
+type layoutCandidate struct {
+	name       string
+	configPath string
+	vardirs    []string
+	codedir    string
+	markers    []string
+}
+
+// marked reports whether the candidate has no markers or one of them exists.
+func (c layoutCandidate) marked() bool {
+	for _, marker := range c.markers {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return len(c.markers) == 0
+}
+
+// userConfigPath is where an agent running as a regular user finds its
+// puppet.conf under its home directory.
+const userConfigPath = ".puppetlabs/etc/puppet/puppet.conf"
+
+// userLayoutCandidate is the layout of an agent running as a regular user
+// whose home directory is home.
+func userLayoutCandidate(home string) layoutCandidate {
+	return layoutCandidate{
+		name:       "user",
+		configPath: filepath.Join(home, userConfigPath),
+		vardirs:    []string{filepath.Join(home, ".puppetlabs/opt/puppet/cache")},
+		codedir:    filepath.Join(home, ".puppetlabs/etc/code"),
+	}
+}
+
+// layout resolves the candidate, picking the first of its vardirs that exists.
+func (c layoutCandidate) layout() Layout {
+	vardir := c.vardirs[0]
+	for _, dir := range c.vardirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			vardir = dir
+			break
+		}
+	}
+	return Layout{Name: c.name, ConfigPath: c.configPath, Vardir: vardir, Codedir: c.codedir}
+}
+
+// DetectLayout returns the first known layout, in the order of
+// layoutCandidates, whose puppet.conf exists, or the AIO layout when there is
+// none.
+func DetectLayout() Layout {
+	return detectLayout(layoutCandidates())
+}
+
+func detectLayout(candidates []layoutCandidate) Layout {
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate.configPath); err == nil && candidate.marked() {
+			return candidate.layout()
+		}
+	}
+	return defaultCandidate(candidates).layout()
+}
+
+// defaultCandidate returns the first candidate without markers, the AIO one,
+// which is assumed when nothing else matches.
+func defaultCandidate(candidates []layoutCandidate) layoutCandidate {
+	for _, candidate := range candidates {
+		if len(candidate.markers) == 0 {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+// layouts memoises LayoutFor by path. puppet.conf is loaded on every scrape,
+// while the layout only changes when packages are installed or removed, so
+// it is resolved once per path and picked up again on restart.
+var layouts sync.Map
+
+// LayoutFor returns the known layout the puppet.conf at path belongs to, or a
+// custom layout with the AIO defaults. A puppet.conf under the
+// .puppetlabs/etc/puppet directory of a home directory belongs to the user
+// layout of that home, whichever user the exporter runs as.
+func LayoutFor(path string) Layout {
+	if layout, ok := layouts.Load(path); ok {
+		return layout.(Layout)
+	}
+	layout := layoutFor(layoutCandidates(), path)
+	layouts.Store(path, layout)
+	return layout
+}
+
+func layoutFor(candidates []layoutCandidate, path string) Layout {
+	path = filepath.Clean(path)
+	for _, candidate := range candidates {
+		if filepath.Clean(candidate.configPath) == path && candidate.marked() {
+			return candidate.layout()
+		}
+	}
+	if home, ok := strings.CutSuffix(filepath.ToSlash(path), "/"+userConfigPath); ok {
+		return userLayoutCandidate(filepath.FromSlash(home)).layout()
+	}
+	layout := defaultCandidate(candidates).layout()
+	layout.Name = "custom"
+	layout.ConfigPath = path
+	return layout
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLayout(t *testing.T) {
+	dir := t.TempDir()
+	mkdir := func(path string) string {
+		t.Helper()
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	touch := func(path string) string {
+		t.Helper()
+		mkdir(filepath.Dir(path))
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	candidates := []layoutCandidate{
+		{name: "openvox", configPath: filepath.Join(dir, "aio/puppet.conf"), vardirs: []string{filepath.Join(dir, "aio/cache")}, codedir: filepath.Join(dir, "aio/code"), markers: []string{filepath.Join(dir, "openvox")}},
+		{name: "aio", configPath: filepath.Join(dir, "aio/puppet.conf"), vardirs: []string{filepath.Join(dir, "aio/cache")}, codedir: filepath.Join(dir, "aio/code")},
+		{name: "debian", configPath: filepath.Join(dir, "debian/puppet.conf"), vardirs: []string{filepath.Join(dir, "debian/cache"), filepath.Join(dir, "debian/lib")}, codedir: filepath.Join(dir, "debian/code")},
+	}
+
+	// Nothing installed: the first layout is assumed.
+	if got := detectLayout(candidates); got.Name != "aio" {
+		t.Errorf("detectLayout() = %+v, want aio", got)
+	}
+
+	// The first existing vardir of the detected layout is used, falling back
+	// to the first one.
+	touch(candidates[2].configPath)
+	if got := detectLayout(candidates); got.Name != "debian" || got.Vardir != filepath.Join(dir, "debian/cache") {
+		t.Errorf("detectLayout() = %+v, want debian with its first vardir", got)
+	}
+	mkdir(filepath.Join(dir, "debian/lib"))
+	want := Layout{Name: "debian", ConfigPath: candidates[2].configPath, Vardir: filepath.Join(dir, "debian/lib"), Codedir: filepath.Join(dir, "debian/code")}
+	if got := detectLayout(candidates); got != want {
+		t.Errorf("detectLayout() = %+v, want %+v", got, want)
+	}
+
+	// Candidates are probed in order.
+	touch(candidates[1].configPath)
+	if got := detectLayout(candidates); got.Name != "aio" {
+		t.Errorf("detectLayout() = %+v, want aio", got)
+	}
+
+	// The marker tells OpenVox apart from Puppet.
+	mkdir(filepath.Join(dir, "openvox"))
+	if got := detectLayout(candidates); got.Name != "openvox" {
+		t.Errorf("detectLayout() = %+v, want openvox", got)
+	}
+}
+
+func TestLayoutFor(t *testing.T) {
+	candidates := []layoutCandidate{
+		{name: "aio", configPath: "/etc/puppetlabs/puppet/puppet.conf", vardirs: []string{"/opt/puppetlabs/puppet/cache"}, codedir: "/etc/puppetlabs/code"},
+		{name: "debian", configPath: "/etc/puppet/puppet.conf", vardirs: []string{"/var/cache/puppet"}, codedir: "/etc/puppet/code"},
+	}
+
+	if got := layoutFor(candidates, "/etc/puppet/puppet.conf"); got.Name != "debian" || got.Codedir != "/etc/puppet/code" {
+		t.Errorf("layoutFor(debian) = %+v, want the debian layout", got)
+	}
+	if got := layoutFor(candidates, "/etc/puppet/./puppet.conf"); got.Name != "debian" {
+		t.Errorf("layoutFor(unclean debian) = %+v, want the debian layout", got)
+	}
+	// The user layout follows the home directory puppet.conf is in, not the
+	// one of the exporter.
+	want := Layout{Name: "user", ConfigPath: "/home/puppet/.puppetlabs/etc/puppet/puppet.conf", Vardir: "/home/puppet/.puppetlabs/opt/puppet/cache", Codedir: "/home/puppet/.puppetlabs/etc/code"}
+	if got := layoutFor(candidates, "/home/puppet/.puppetlabs/etc/puppet/puppet.conf"); got != want {
+		t.Errorf("layoutFor(user) = %+v, want %+v", got, want)
+	}
+	want = Layout{Name: "custom", ConfigPath: "/srv/puppet.conf", Vardir: "/opt/puppetlabs/puppet/cache", Codedir: "/etc/puppetlabs/code"}
+	if got := layoutFor(candidates, "/srv/puppet.conf"); got != want {
+		t.Errorf("layoutFor(custom) = %+v, want %+v", got, want)
+	}
+}
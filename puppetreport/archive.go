@@ -0,0 +1,290 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+var (
+	archiveRunsDesc = prometheus.NewDesc(
+		"puppet_reports_runs",
+		"Number of Puppet runs in the reports archive within the window.",
+		nil,
+		nil,
+	)
+	archiveFailedRunsDesc = prometheus.NewDesc(
+		"puppet_reports_failed_runs",
+		"Number of failed Puppet runs in the reports archive within the window.",
+		nil,
+		nil,
+	)
+	archiveSuccessRatioDesc = prometheus.NewDesc(
+		"puppet_reports_success_ratio",
+		"Ratio of successful Puppet runs in the reports archive within the window.",
+		nil,
+		nil,
+	)
+	archiveDurationDesc = prometheus.NewDesc(
+		"puppet_reports_run_duration_seconds",
+		"Duration of the Puppet runs in the reports archive within the window.",
+		nil,
+		nil,
+	)
+	archiveWindowDesc = prometheus.NewDesc(
+		"puppet_reports_window_seconds",
+		"Window of recent Puppet runs the reports archive metrics cover.",
+		nil,
+		nil,
+	)
+	archiveScrapeErrorDesc = prometheus.NewDesc(
+		"puppet_reports_scrape_error",
+		"1 if there was an error opening or reading a file, 0 otherwise",
+		nil,
+		nil,
+	)
+)
+
+// DefaultArchiveWindow is the default window of recent runs the reports
+// archive metrics cover.
+const DefaultArchiveWindow = 24 * time.Hour
+
+// archiveQuantiles are the run duration quantiles exported from the archive.
+var archiveQuantiles = []float64{0.5, 0.9, 0.99}
+
+// ArchiveCollector exports the run history Puppet keeps in its reports
+// directory when it is configured with reports = store. Every report is only
+// parsed once, corrupt ones included, and reports older than the window are not
+// parsed at all.
+type ArchiveCollector struct {
+	Logger *slog.Logger
+	// ReportsDir is Puppet's reports directory, $vardir/reports, holding a
+	// subdirectory of reports per certname.
+	ReportsDir string
+	// Certname is the certname of the node, the only subdirectory of
+	// ReportsDir read: a Puppet server also stores the reports of the nodes
+	// it serves there.
+	Certname string
+	Window   time.Duration
+
+	// now is overridden by tests.
+	now func() time.Time
+
+	mu   sync.Mutex
+	runs map[string]archivedRun
+}
+
+// archivedRun is what the archive keeps of each report file.
+type archivedRun struct {
+	size    int64
+	modTime time.Time
+	// parsed is false for files that were already out of the window when
+	// first seen, which are never read.
+	parsed   bool
+	runAt    float64
+	success  bool
+	duration float64
+	// err is the error reading a corrupt report, reported again on every
+	// scrape until the file changes.
+	err error
+}
+
+// archivedReport holds the fields of a report the archive needs, so that the
+// resource statuses and logs making up most of a report are not decoded.
+type archivedReport struct {
+	Time                 time.Time                   `yaml:"time"`
+	TransactionCompleted bool                        `yaml:"transaction_completed"`
+	Metrics              map[string]puppetUtilMetric `yaml:"metrics"`
+}
+
+// reportNameLayout is the layout of the names Puppet's store report processor
+// gives report files, from the UTC time they were stored at.
+const reportNameLayout = "200601021504"
+
+func (c *ArchiveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- archiveRunsDesc
+	ch <- archiveFailedRunsDesc
+	ch <- archiveSuccessRatioDesc
+	ch <- archiveDurationDesc
+	ch <- archiveWindowDesc
+	ch <- archiveScrapeErrorDesc
+}
+
+func (c *ArchiveCollector) Collect(ch chan<- prometheus.Metric) {
+	var errVal float64
+	runs, err := c.scan()
+	if err != nil {
+		c.Logger.Error("Failed to read puppet reports directory", "err", err)
+		errVal = 1.0
+	}
+
+	var failed float64
+	var durations []float64
+	durationSum := 0.0
+	for _, run := range runs {
+		if !run.success {
+			failed++
+		}
+		if !math.IsNaN(run.duration) {
+			durations = append(durations, run.duration)
+			durationSum += run.duration
+		}
+	}
+
+	successRatio := math.NaN()
+	if len(runs) > 0 {
+		successRatio = (float64(len(runs)) - failed) / float64(len(runs))
+	}
+
+	ch <- prometheus.MustNewConstMetric(archiveRunsDesc, prometheus.GaugeValue, float64(len(runs)))
+	ch <- prometheus.MustNewConstMetric(archiveFailedRunsDesc, prometheus.GaugeValue, failed)
+	ch <- prometheus.MustNewConstMetric(archiveSuccessRatioDesc, prometheus.GaugeValue, successRatio)
+	ch <- prometheus.MustNewConstSummary(archiveDurationDesc, uint64(len(durations)), durationSum, quantiles(durations, archiveQuantiles))
+	ch <- prometheus.MustNewConstMetric(archiveWindowDesc, prometheus.GaugeValue, c.window().Seconds())
+	ch <- prometheus.MustNewConstMetric(archiveScrapeErrorDesc, prometheus.GaugeValue, errVal)
+}
+
+func (c *ArchiveCollector) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return DefaultArchiveWindow
+}
+
+// scan brings the known runs up to date with the reports directory of the
+// node and returns those within the window. Unreadable reports are skipped so
+// that one corrupt file does not hide the rest of the history; the error is
+// still returned.
+func (c *ArchiveCollector) scan() ([]archivedRun, error) {
+	dir := filepath.Join(c.ReportsDir, c.Certname)
+	// Glob does not report a missing directory, which must not pass for an
+	// empty history.
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	windowStart := now().Add(-c.window())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]archivedRun, len(paths))
+	var errs []error
+	for _, path := range paths {
+		// A report is stored when its run ends, so a report named before
+		// the window holds a run that started before it too, and is not
+		// even looked at. The name is truncated to the minute.
+		if storedAt, ok := reportStoredAt(path); ok && storedAt.Add(time.Minute).Before(windowStart) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		run, ok := c.runs[path]
+		if !ok || run.size != info.Size() || !run.modTime.Equal(info.ModTime()) {
+			run = archivedRun{size: info.Size(), modTime: info.ModTime()}
+			// Files not named by Puppet are told by their modification
+			// time instead.
+			if !info.ModTime().Before(windowStart) {
+				run.load(path)
+			}
+		}
+		if run.err != nil {
+			errs = append(errs, run.err)
+		}
+		seen[path] = run
+	}
+	// Reports removed from the directory are forgotten.
+	c.runs = seen
+
+	windowStartSeconds := asUnixSeconds(windowStart)
+	var result []archivedRun
+	for _, run := range c.runs {
+		if run.parsed && run.runAt >= windowStartSeconds {
+			result = append(result, run)
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// reportStoredAt returns the time a report was stored at from its name, when
+// Puppet named it.
+func reportStoredAt(path string) (time.Time, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	storedAt, err := time.Parse(reportNameLayout, name)
+	return storedAt, err == nil
+}
+
+// load reads the start, outcome and duration of the run of the report at
+// path, or the error reading it.
+func (run *archivedRun) load(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		run.err = err
+		return
+	}
+	var archived archivedReport
+	err = yaml.NewDecoder(file).Decode(&archived)
+	if err = errors.Join(err, file.Close()); err != nil {
+		run.err = err
+		return
+	}
+
+	report := runReport{Time: archived.Time, TransactionCompleted: archived.TransactionCompleted, Metrics: archived.Metrics}
+	run.parsed = true
+	run.runAt = asUnixSeconds(report.Time)
+	run.success = report.isSuccess(report.resourcesMetrics()) == 1
+	run.duration = report.totalDuration()
+}
+
+// quantiles returns the nearest-rank quantiles of values.
+func quantiles(values []float64, qs []float64) map[float64]float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	result := make(map[float64]float64, len(qs))
+	for _, q := range qs {
+		if len(sorted) == 0 {
+			result[q] = math.NaN()
+			continue
+		}
+		rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+		result[q] = sorted[max(rank, 0)]
+	}
+	return result
+}
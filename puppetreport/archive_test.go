@@ -0,0 +1,212 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+var archiveNow = time.Date(2021, 4, 21, 12, 0, 0, 0, time.UTC)
+
+// archiveCertname is the certname the archived reports are stored for.
+const archiveCertname = "node.example.com"
+
+// archiveReport writes a report of a run that started at runAt into the
+// certname directory of dir, with the modification time Puppet would give it.
+func archiveReport(t *testing.T, dir string, runAt time.Time, content string) {
+	t.Helper()
+	archiveNodeReport(t, dir, archiveCertname, runAt, content)
+}
+
+// archiveNodeReport is archiveReport for the reports of the node certname.
+func archiveNodeReport(t *testing.T, dir, certname string, runAt time.Time, content string) {
+	t.Helper()
+	certDir := filepath.Join(dir, certname)
+	if err := os.MkdirAll(certDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(certDir, runAt.Format("200601021504")+".yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, runAt, runAt.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func archivedRunReport(runAt time.Time, failed int, duration float64) string {
+	return fmt.Sprintf(`--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '%s'
+transaction_completed: true
+metrics:
+  resources:
+    name: resources
+    values:
+    - - failed
+      - Failed
+      - %d
+  time:
+    name: time
+    values:
+    - - total
+      - Total
+      - %g
+`, runAt.Format(time.RFC3339Nano), failed, duration)
+}
+
+func TestArchiveCollector(t *testing.T) {
+	dir := t.TempDir()
+	for i, run := range []struct {
+		failed   int
+		duration float64
+	}{
+		{failed: 0, duration: 20},
+		{failed: 2, duration: 40},
+		{failed: 0, duration: 30},
+		{failed: 0, duration: 10},
+	} {
+		runAt := archiveNow.Add(-time.Duration(i+1) * time.Hour)
+		archiveReport(t, dir, runAt, archivedRunReport(runAt, run.failed, run.duration))
+	}
+	// Out of the window: it is neither counted nor even parsed.
+	archiveReport(t, dir, archiveNow.Add(-48*time.Hour), "not a report")
+	// Named out of the window by Puppet: it is not even looked at.
+	oldRun := archiveNow.Add(-36 * time.Hour)
+	archiveReport(t, dir, oldRun, "not a report")
+	if err := os.Chtimes(filepath.Join(dir, archiveCertname, oldRun.Format("200601021504")+".yaml"), archiveNow, archiveNow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reports stored for another node, as on a Puppet server, are left out.
+	otherRun := archiveNow.Add(-2 * time.Hour)
+	archiveNodeReport(t, dir, "other.example.com", otherRun, archivedRunReport(otherRun, 3, 600))
+
+	c := &ArchiveCollector{Logger: promslog.NewNopLogger(), ReportsDir: dir, Certname: archiveCertname, now: func() time.Time { return archiveNow }}
+
+	expected := `
+# HELP puppet_reports_failed_runs Number of failed Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_failed_runs gauge
+puppet_reports_failed_runs 1
+# HELP puppet_reports_run_duration_seconds Duration of the Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_run_duration_seconds summary
+puppet_reports_run_duration_seconds{quantile="0.5"} 20
+puppet_reports_run_duration_seconds{quantile="0.9"} 40
+puppet_reports_run_duration_seconds{quantile="0.99"} 40
+puppet_reports_run_duration_seconds_sum 100
+puppet_reports_run_duration_seconds_count 4
+# HELP puppet_reports_runs Number of Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_runs gauge
+puppet_reports_runs 4
+# HELP puppet_reports_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
+# TYPE puppet_reports_scrape_error gauge
+puppet_reports_scrape_error 0
+# HELP puppet_reports_success_ratio Ratio of successful Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_success_ratio gauge
+puppet_reports_success_ratio 0.75
+# HELP puppet_reports_window_seconds Window of recent Puppet runs the reports archive metrics cover.
+# TYPE puppet_reports_window_seconds gauge
+puppet_reports_window_seconds 86400
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run lands in the directory: only it is parsed, and it shows up.
+	runAt := archiveNow.Add(-10 * time.Minute)
+	archiveReport(t, dir, runAt, archivedRunReport(runAt, 1, 50))
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP puppet_reports_failed_runs Number of failed Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_failed_runs gauge
+puppet_reports_failed_runs 2
+`), "puppet_reports_failed_runs"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveCollectorCorruptReport(t *testing.T) {
+	dir := t.TempDir()
+	runAt := archiveNow.Add(-time.Hour)
+	report := archivedRunReport(runAt, 0, 20)
+	archiveReport(t, dir, runAt, strings.Repeat("\t", len(report)))
+
+	c := &ArchiveCollector{Logger: promslog.NewNopLogger(), ReportsDir: dir, Certname: archiveCertname, now: func() time.Time { return archiveNow }}
+	names := []string{"puppet_reports_runs", "puppet_reports_scrape_error"}
+	failing := `
+# HELP puppet_reports_runs Number of Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_runs gauge
+puppet_reports_runs 0
+# HELP puppet_reports_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
+# TYPE puppet_reports_scrape_error gauge
+puppet_reports_scrape_error 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(failing), names...); err != nil {
+		t.Fatal(err)
+	}
+
+	// A corrupt report is not parsed again while its size and modification
+	// time are unchanged, but its error is still reported.
+	archiveReport(t, dir, runAt, report)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(failing), names...); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, archiveCertname, runAt.Format("200601021504")+".yaml")
+	if err := os.Chtimes(path, runAt, runAt.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP puppet_reports_runs Number of Puppet runs in the reports archive within the window.
+# TYPE puppet_reports_runs gauge
+puppet_reports_runs 1
+# HELP puppet_reports_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
+# TYPE puppet_reports_scrape_error gauge
+puppet_reports_scrape_error 0
+`), names...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveCollectorMissingDirectory(t *testing.T) {
+	c := &ArchiveCollector{Logger: promslog.NewNopLogger(), ReportsDir: filepath.Join(t.TempDir(), "absent"), Certname: archiveCertname}
+
+	expected := `
+# HELP puppet_reports_scrape_error 1 if there was an error opening or reading a file, 0 otherwise
+# TYPE puppet_reports_scrape_error gauge
+puppet_reports_scrape_error 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_reports_scrape_error"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveDescribeCoversCollect(t *testing.T) {
+	c := &ArchiveCollector{Logger: promslog.NewNopLogger(), ReportsDir: t.TempDir()}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("pedantic gather: %v", err)
+	}
+}
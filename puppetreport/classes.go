@@ -0,0 +1,93 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// mainClass is the class holding the top-scope manifest.
+const mainClass = "Main"
+
+// classResourceCounts counts the failed and changed resources contained in a
+// class.
+type classResourceCounts struct {
+	Class   string
+	Failed  float64
+	Changed float64
+}
+
+// classResources attributes the failed and changed resources of the run to the
+// first class of their containment path, or to Main when no class contains
+// them, so that every such resource is counted. The classes are sorted by
+// failures, then changes, so that a limit applied to them keeps the most
+// relevant ones.
+func (r runReport) classResources() []classResourceCounts {
+	counts := make(map[string]*classResourceCounts)
+	for _, status := range r.ResourceStatuses {
+		if !status.Failed && !status.Changed {
+			continue
+		}
+		class := firstClass(status.ContainmentPath)
+		if class == "" {
+			class = mainClass
+		}
+		count, ok := counts[class]
+		if !ok {
+			count = &classResourceCounts{Class: class}
+			counts[class] = count
+		}
+		count.Failed += asFloat(status.Failed)
+		count.Changed += asFloat(status.Changed)
+	}
+
+	var result []classResourceCounts
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	slices.SortFunc(result, func(a, b classResourceCounts) int {
+		if c := cmp.Compare(b.Failed, a.Failed); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Changed, a.Changed); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Class, b.Class)
+	})
+	return result
+}
+
+// firstClass returns the first class of a containment path such as
+// [Stage[main], Profile::Nginx, Nginx::Config, File[/etc/nginx/nginx.conf]].
+// Stages, defined types and resources are references with a title in
+// brackets, while classes are plain names. Main, the class holding the
+// top-scope manifest, is only used when nothing more specific contains the
+// resource.
+func firstClass(path []string) string {
+	var main string
+	for _, entry := range path {
+		switch {
+		case strings.Contains(entry, "["):
+			continue
+		case entry == mainClass:
+			main = entry
+		default:
+			return entry
+		}
+	}
+	return main
+}
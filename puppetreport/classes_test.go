@@ -0,0 +1,60 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFirstClass(t *testing.T) {
+	for _, tc := range []struct {
+		path []string
+		want string
+	}{
+		{path: []string{"Stage[main]", "Profile::Nginx", "Nginx::Config", "File[/etc/nginx/nginx.conf]"}, want: "Profile::Nginx"},
+		{path: []string{"Stage[main]", "Nginx::Vhost[default]", "File[/etc/nginx/sites-enabled/default]"}, want: ""},
+		{path: []string{"Stage[main]", "Main", "Node[default]", "Profile::Base", "Package[vim]"}, want: "Profile::Base"},
+		{path: []string{"Stage[main]", "Main", "File[/etc/motd]"}, want: "Main"},
+		{path: nil, want: ""},
+	} {
+		if got := firstClass(tc.path); got != tc.want {
+			t.Errorf("firstClass(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestClassResources(t *testing.T) {
+	report := runReport{ResourceStatuses: map[string]resourceStatus{
+		"Package[nginx]":              {Failed: true, ContainmentPath: []string{"Stage[main]", "Profile::Nginx", "Nginx::Package", "Package[nginx]"}},
+		"File[/etc/nginx/nginx.conf]": {Changed: true, ContainmentPath: []string{"Stage[main]", "Profile::Nginx", "Nginx::Config", "File[/etc/nginx/nginx.conf]"}},
+		"File[/etc/motd]":             {Changed: true, ContainmentPath: []string{"Stage[main]", "Profile::Base", "File[/etc/motd]"}},
+		"User[deploy]":                {Changed: true, ContainmentPath: []string{"Stage[main]", "Profile::Accounts", "User[deploy]"}},
+		"File[/etc/issue]":            {ContainmentPath: []string{"Stage[main]", "Profile::Base", "File[/etc/issue]"}},
+		"File[/etc/nginx/conf.d/app]": {Failed: true, ContainmentPath: []string{"Stage[main]", "Nginx::Vhost[app]", "File[/etc/nginx/conf.d/app]"}},
+	}}
+
+	// Failures first, then changes, then by name. Resources no class
+	// contains are counted under Main.
+	want := []classResourceCounts{
+		{Class: "Profile::Nginx", Failed: 1, Changed: 1},
+		{Class: "Main", Failed: 1},
+		{Class: "Profile::Accounts", Changed: 1},
+		{Class: "Profile::Base", Changed: 1},
+	}
+	if got := report.classResources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("classResources() = %+v, want %+v", got, want)
+	}
+}
@@ -16,8 +16,13 @@ package puppetreport
 
 import (
 	"log/slog"
+	"math"
+	"slices"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/fgouteroux/puppet-agent-exporter/puppetconfig"
 )
 
 var (
@@ -60,18 +65,213 @@ var (
 		[]string{"type"},
 		nil,
 	)
+	eventsByPropertyDesc = prometheus.NewDesc(
+		"puppet_last_run_events_by_property",
+		"Events of the last Puppet run by changed property and status.",
+		[]string{"property", "status"},
+		nil,
+	)
 	runChangesDesc = prometheus.NewDesc(
 		"puppet_last_run_report_changes",
 		"Changes of the last Puppet run",
 		[]string{"type"},
 		nil,
 	)
+	reportMetricDesc = prometheus.NewDesc(
+		"puppet_last_run_report_metric",
+		"Value of a metric of the last Puppet run report, by metric group and name.",
+		[]string{"group", "name"},
+		nil,
+	)
 	runReportTimeDurationDesc = prometheus.NewDesc(
 		"puppet_last_run_report_time_duration_seconds",
 		"Resources duration of the last Puppet run.",
 		[]string{"type"},
 		nil,
 	)
+	runInfoDesc = prometheus.NewDesc(
+		"puppet_last_run_info",
+		"Identity of the last Puppet run report.",
+		[]string{"host", "environment", "puppet_version", "report_format", "catalog_uuid", "transaction_uuid", "code_id", "server_used"},
+		nil,
+	)
+	runStatusDesc = prometheus.NewDesc(
+		"puppet_last_run_status",
+		"Status Puppet recorded for the last run, 1 for the current one.",
+		[]string{"status"},
+		nil,
+	)
+	runNoopDesc = prometheus.NewDesc(
+		"puppet_last_run_noop",
+		"1 if the last Puppet run was a noop run.",
+		nil,
+		nil,
+	)
+	runNoopPendingDesc = prometheus.NewDesc(
+		"puppet_last_run_noop_pending",
+		"1 if the last Puppet run held back changes because of noop.",
+		nil,
+		nil,
+	)
+	cachedCatalogStatusDesc = prometheus.NewDesc(
+		"puppet_last_run_cached_catalog_status",
+		"Whether the last Puppet run applied a cached catalog, 1 for the current status.",
+		[]string{"status"},
+		nil,
+	)
+	failureReasonDesc = prometheus.NewDesc(
+		"puppet_last_run_failure_reason",
+		"Why the last Puppet run failed, 1 for the identified reason.",
+		[]string{"reason"},
+		nil,
+	)
+	failedResourceDesc = prometheus.NewDesc(
+		"puppet_last_run_failed_resource",
+		"Resources that failed during the last Puppet run.",
+		[]string{"type", "title"},
+		nil,
+	)
+	failedResourceOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_failed_resource_overflow",
+		"Number of failed resources of the last Puppet run left out of puppet_last_run_failed_resource by the limit.",
+		nil,
+		nil,
+	)
+	failedFilesDesc = prometheus.NewDesc(
+		"puppet_last_run_failed_resources_by_file",
+		"Number of resources that failed during the last Puppet run by the manifest file declaring them.",
+		[]string{"file"},
+		nil,
+	)
+	failedFilesOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_failed_resources_by_file_overflow",
+		"Number of manifest files of the last Puppet run left out of puppet_last_run_failed_resources_by_file by the limit.",
+		nil,
+		nil,
+	)
+	dependencySkippedResourcesDesc = prometheus.NewDesc(
+		"puppet_last_run_dependency_skipped_resources",
+		"Number of resources the last Puppet run skipped because of failed dependencies.",
+		nil,
+		nil,
+	)
+	dependencyFailureDesc = prometheus.NewDesc(
+		"puppet_last_run_dependency_failure",
+		"Failed resources of the last Puppet run that caused others to be skipped, with the number of resources skipped because of them.",
+		[]string{"type", "title"},
+		nil,
+	)
+	dependencyFailureOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_dependency_failure_overflow",
+		"Number of failed resources of the last Puppet run that caused others to be skipped left out of puppet_last_run_dependency_failure by the limit.",
+		nil,
+		nil,
+	)
+	resourceEvaluationDesc = prometheus.NewDesc(
+		"puppet_last_run_resource_evaluation_seconds",
+		"Evaluation time of the slowest resources of the last Puppet run.",
+		[]string{"type", "title"},
+		nil,
+	)
+	resourcesByTypeDesc = prometheus.NewDesc(
+		"puppet_last_run_resources_by_type",
+		"Resources of the last Puppet run by resource type and state.",
+		[]string{"resource_type", "state"},
+		nil,
+	)
+	classResourcesDesc = prometheus.NewDesc(
+		"puppet_last_run_class_resources",
+		"Failed and changed resources of the last Puppet run by the first class containing them.",
+		[]string{"class", "state"},
+		nil,
+	)
+	classResourcesOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_class_resources_overflow",
+		"Number of classes of the last Puppet run left out of puppet_last_run_class_resources by the limit.",
+		nil,
+		nil,
+	)
+	warningMessagesDesc = prometheus.NewDesc(
+		"puppet_last_run_warning_messages",
+		"Number of warning messages of the last Puppet run by message fingerprint, with numbers, paths and quoted values other than identifiers left out.",
+		[]string{"fingerprint"},
+		nil,
+	)
+	warningMessagesOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_warning_messages_overflow",
+		"Number of warning fingerprints of the last Puppet run left out of puppet_last_run_warning_messages by the limit.",
+		nil,
+		nil,
+	)
+	packageChangeDesc = prometheus.NewDesc(
+		"puppet_last_run_package_change",
+		"Packages the last Puppet run installed, upgraded, downgraded or removed.",
+		[]string{"package", "from", "to"},
+		nil,
+	)
+	// packageChangesDesc is a gauge rather than a counter: it is the number
+	// of changes of the last run, which the next run replaces, and so goes
+	// down as well as up.
+	packageChangesDesc = prometheus.NewDesc(
+		"puppet_last_run_package_changes",
+		"Number of package changes made by the last Puppet run, including those left out of puppet_last_run_package_change by the limit.",
+		nil,
+		nil,
+	)
+	serviceRestartedDesc = prometheus.NewDesc(
+		"puppet_last_run_service_restarted",
+		"Services the last Puppet run restarted or refreshed.",
+		[]string{"service"},
+		nil,
+	)
+	serviceRestartedOverflowDesc = prometheus.NewDesc(
+		"puppet_last_run_service_restarted_overflow",
+		"Number of services of the last Puppet run left out of puppet_last_run_service_restarted by the limit.",
+		nil,
+		nil,
+	)
+	resourceEvaluationHistogramDesc = prometheus.NewDesc(
+		resourceEvaluationHistogramName,
+		resourceEvaluationHistogramHelp,
+		nil,
+		nil,
+	)
+	logMessagesDesc = prometheus.NewDesc(
+		"puppet_last_run_log_messages",
+		"Number of log messages of the last Puppet run by level.",
+		[]string{"level"},
+		nil,
+	)
+	nextRunExpectedAtDesc = prometheus.NewDesc(
+		"puppet_next_run_expected_at_seconds",
+		"Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.",
+		nil,
+		nil,
+	)
+	runOverdueDesc = prometheus.NewDesc(
+		"puppet_run_overdue_seconds",
+		"Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.",
+		nil,
+		nil,
+	)
+	environmentMismatchDesc = prometheus.NewDesc(
+		"puppet_environment_mismatch",
+		"1 if the last Puppet run applied another environment than the one configured in puppet.conf.",
+		[]string{"configured", "applied"},
+		nil,
+	)
+	lastSuccessfulRunAtDesc = prometheus.NewDesc(
+		"puppet_last_successful_run_at_seconds",
+		"Time of the last successful Puppet run seen by the exporter.",
+		nil,
+		nil,
+	)
+	consecutiveFailedRunsDesc = prometheus.NewDesc(
+		"puppet_consecutive_failed_runs",
+		"Number of Puppet runs that failed since the last successful one.",
+		nil,
+		nil,
+	)
 	scrapeErrorDesc = prometheus.NewDesc(
 		"puppet_last_run_scrape_error",
 		"1 if there was an error opening or reading a file, 0 otherwise",
@@ -80,11 +280,105 @@ var (
 	)
 )
 
+// The evaluation time histogram is built afresh by every scrape, so its
+// descriptor and the histogram, classic and native buckets alike, are both
+// declared from these. The name sets it apart from the
+// puppet_last_run_resource_evaluation_seconds gauge of the slowest resources.
+const (
+	resourceEvaluationHistogramName = "puppet_last_run_resource_evaluation_duration_seconds"
+	resourceEvaluationHistogramHelp = "Distribution of the evaluation time of the resources of the last Puppet run."
+)
+
+// DefaultResourceEvaluationBuckets are the default buckets of the resource
+// evaluation time histogram. Most resources take milliseconds, while package
+// and exec resources may take minutes.
+var DefaultResourceEvaluationBuckets = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// runStatuses are the statuses Puppet records in a report. They are always
+// exported, so that a change of status does not leave a gap in the series.
+var runStatuses = []string{"failed", "changed", "unchanged"}
+
+// cachedCatalogStatuses are the values of the report's cached_catalog_status.
+// on_failure means the agent could not get a fresh catalog from the server.
+var cachedCatalogStatuses = []string{"not_used", "explicitly_requested", "on_failure"}
+
+// DefaultFailedResourcesLimit is the default number of failed resources
+// exported by name.
+const DefaultFailedResourcesLimit = 20
+
+// DefaultDependencyFailuresLimit is the default number of root dependency
+// failures exported by name.
+const DefaultDependencyFailuresLimit = 20
+
+// DefaultFailedFilesLimit is the default number of manifest files failed
+// resources are counted by.
+const DefaultFailedFilesLimit = 20
+
+// DefaultPackageChangesLimit is the default number of package changes exported
+// by name.
+const DefaultPackageChangesLimit = 50
+
+// DefaultServiceRestartsLimit is the default number of restarted services
+// exported by name.
+const DefaultServiceRestartsLimit = 20
+
+// DefaultSlowestResourcesLimit is the default number of slowest resources
+// exported with their evaluation time.
+const DefaultSlowestResourcesLimit = 10
+
 type Collector struct {
 	Logger     *slog.Logger
 	ReportPath string
+	// ConfigPath is the puppet.conf the applied environment is compared
+	// against, and whose runinterval and splay set when the next run is
+	// expected. The comparison, puppet_next_run_expected_at_seconds and
+	// puppet_run_overdue_seconds are skipped when it is empty.
+	ConfigPath string
+	// StatePath is where the exporter persists what it saw of past runs.
+	// The run state metrics are not exported when it is empty.
+	StatePath string
+	// FailedResourcesLimit bounds the puppet_last_run_failed_resource series,
+	// so that a run failing on every resource cannot blow up the cardinality.
+	FailedResourcesLimit int
+	// DependencyFailuresLimit bounds the puppet_last_run_dependency_failure
+	// series.
+	DependencyFailuresLimit int
+	// FailedFilesLimit bounds the puppet_last_run_failed_resources_by_file
+	// series.
+	FailedFilesLimit int
+	// SlowestResourcesLimit is the number of slowest resources exported with
+	// their evaluation time.
+	SlowestResourcesLimit int
+	// ResourceEvaluationBuckets are the buckets of the resource evaluation
+	// time histogram, DefaultResourceEvaluationBuckets when empty.
+	ResourceEvaluationBuckets []float64
+	// ResourceEvaluationNativeFactor is the bucket growth factor of the native
+	// histogram exposed alongside the classic one to scrapers negotiating it.
+	// Native histograms are disabled when it is not greater than 1.
+	ResourceEvaluationNativeFactor float64
+	// GenericReportMetrics exports every metric group of the report as
+	// puppet_last_run_report_metric, restricted to the groups in
+	// GenericReportMetricsAllow when it is not empty, and never those in
+	// GenericReportMetricsDeny.
+	GenericReportMetrics      bool
+	GenericReportMetricsAllow []string
+	GenericReportMetricsDeny  []string
+	// PackageChangesLimit bounds the puppet_last_run_package_change series.
+	PackageChangesLimit int
+	// ServiceRestartsLimit bounds the puppet_last_run_service_restarted
+	// series.
+	ServiceRestartsLimit int
+	// ClassResourcesLimit is the number of classes failed and changed
+	// resources are attributed to. The attribution is disabled when it is 0.
+	ClassResourcesLimit int
+	// WarningFingerprintsLimit is the number of distinct warning message
+	// fingerprints exported. Warning messages are not exported when it is 0.
+	WarningFingerprintsLimit int
 
 	cache reportCache
+	state stateFile
+	// now is overridden by tests.
+	now func() time.Time
 }
 
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
@@ -92,34 +386,178 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- runAtDesc
 	ch <- runDurationDesc
 	ch <- runSuccessDesc
+	ch <- runInfoDesc
+	ch <- runStatusDesc
+	ch <- runNoopDesc
+	ch <- runNoopPendingDesc
+	ch <- cachedCatalogStatusDesc
+	ch <- failureReasonDesc
 	ch <- runResourcesDesc
 	ch <- runEventsDesc
+	ch <- eventsByPropertyDesc
 	ch <- runChangesDesc
 	ch <- runReportTimeDurationDesc
+	ch <- reportMetricDesc
+	ch <- failedResourceDesc
+	ch <- failedResourceOverflowDesc
+	ch <- failedFilesDesc
+	ch <- failedFilesOverflowDesc
+	ch <- dependencySkippedResourcesDesc
+	ch <- dependencyFailureDesc
+	ch <- dependencyFailureOverflowDesc
+	ch <- resourceEvaluationDesc
+	ch <- resourcesByTypeDesc
+	ch <- classResourcesDesc
+	ch <- classResourcesOverflowDesc
+	ch <- warningMessagesDesc
+	ch <- warningMessagesOverflowDesc
+	ch <- packageChangeDesc
+	ch <- packageChangesDesc
+	ch <- serviceRestartedDesc
+	ch <- serviceRestartedOverflowDesc
+	ch <- resourceEvaluationHistogramDesc
+	ch <- logMessagesDesc
+	ch <- environmentMismatchDesc
+	ch <- nextRunExpectedAtDesc
+	ch <- runOverdueDesc
+	ch <- lastSuccessfulRunAtDesc
+	ch <- consecutiveFailedRunsDesc
 	ch <- scrapeErrorDesc
 }
 
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	var errVal float64
+	var observed *interpretedReport
 	if report, err := c.cache.get(c.reportPath()); err != nil {
 		c.Logger.Error("Failed to read puppet run report file", "err", err)
 		errVal = 1.0
 	} else {
 		report.collect(ch)
+		report.collectFailedResources(ch, c.FailedResourcesLimit)
+		report.collectFailedFiles(ch, c.FailedFilesLimit)
+		report.collectDependencyFailures(ch, c.DependencyFailuresLimit)
+		report.collectSlowestResources(ch, c.SlowestResourcesLimit)
+		report.collectResourceEvaluationHistogram(ch, c.resourceEvaluationBuckets(), c.ResourceEvaluationNativeFactor)
+		report.collectClassResources(ch, c.ClassResourcesLimit)
+		report.collectWarningMessages(ch, c.WarningFingerprintsLimit)
+		report.collectPackageChanges(ch, c.PackageChangesLimit)
+		report.collectRestartedServices(ch, c.ServiceRestartsLimit)
+		if c.GenericReportMetrics {
+			report.collectMetricGroups(ch, c.GenericReportMetricsAllow, c.GenericReportMetricsDeny)
+		}
+		c.collectConfigured(ch, report)
+		observed = &report
+	}
+
+	// The run state is exported even when the report cannot be read: knowing
+	// when the last good run was matters most when things are broken.
+	if c.StatePath != "" {
+		c.collectRunState(ch, observed)
 	}
 
 	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, errVal)
 }
 
+func (c *Collector) collectRunState(ch chan<- prometheus.Metric, report *interpretedReport) {
+	state, err := c.state.update(c.StatePath, report)
+	if err != nil {
+		c.Logger.Error("Failed to persist puppet run state", "err", err)
+	}
+	ch <- prometheus.MustNewConstMetric(lastSuccessfulRunAtDesc, prometheus.GaugeValue, state.lastSuccessAt())
+	ch <- prometheus.MustNewConstMetric(consecutiveFailedRunsDesc, prometheus.GaugeValue, state.ConsecutiveFailures)
+}
+
+// collectConfigured exports the metrics comparing the last run with what
+// puppet.conf configures.
+func (c *Collector) collectConfigured(ch chan<- prometheus.Metric, report interpretedReport) {
+	if c.ConfigPath == "" {
+		return
+	}
+
+	// puppet_config_scrape_error already counts errors reading puppet.conf,
+	// but they are logged here too as they take the environment mismatch
+	// away, and the next run is then expected at the default runinterval.
+	config, err := puppetconfig.Load(c.ConfigPath)
+	if err != nil {
+		c.Logger.Warn("Failed to open puppet config file, expecting the next run at the default runinterval", "err", err)
+		c.collectNextRun(ch, DefaultRunInterval, report)
+		return
+	}
+	collectEnvironmentMismatch(ch, config, report)
+	c.collectNextRun(ch, nextRunDelay(config), report)
+}
+
+// collectEnvironmentMismatch compares the environment the last run applied with
+// the one puppet.conf requests. They differ when the node classifier overrides
+// the environment, or when the requested one no longer exists on the server.
+func collectEnvironmentMismatch(ch chan<- prometheus.Metric, config *puppetconfig.Config, report interpretedReport) {
+	if report.Info.Environment == "" {
+		return
+	}
+
+	configured := config.Setting("environment")
+	if configured == "" {
+		configured = puppetconfig.DefaultEnvironment
+	}
+
+	var mismatch float64
+	if configured != report.Info.Environment {
+		mismatch = 1
+	}
+	ch <- prometheus.MustNewConstMetric(environmentMismatchDesc, prometheus.GaugeValue, mismatch, configured, report.Info.Environment)
+}
+
+// collectNextRun exports when the agent daemon is expected to start its next
+// run at the latest, delay after the start of the last one, and by how long
+// that is overdue. A run lasting longer than delay pushes the next one back to
+// its end.
+func (c *Collector) collectNextRun(ch chan<- prometheus.Metric, delay time.Duration, report interpretedReport) {
+	if report.RunAt <= 0 {
+		return
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	expected := report.RunAt + delay.Seconds()
+	if !math.IsNaN(report.RunDuration) {
+		expected = max(expected, report.RunAt+report.RunDuration)
+	}
+	overdue := max(asUnixSeconds(now())-expected, 0)
+	ch <- prometheus.MustNewConstMetric(nextRunExpectedAtDesc, prometheus.GaugeValue, expected)
+	ch <- prometheus.MustNewConstMetric(runOverdueDesc, prometheus.GaugeValue, overdue)
+}
+
 type interpretedReport struct {
 	RunAt                 float64
 	RunDuration           float64
 	CatalogVersion        float64
 	RunSuccess            float64
+	FailureReason         string
+	Status                string
+	Noop                  float64
+	NoopPending           float64
+	CachedCatalogStatus   string
+	Info                  reportInfo
 	RunReportResources    map[string]float64
 	RunReportEvents       map[string]float64
 	RunReportChanges      map[string]float64
 	RunReportTimeDuration map[string]float64
+	FailedResources       []resourceRef
+	FailedFiles           []fileFailures
+	ResourceTimes         []resourceTime
+	LogMessages           map[string]float64
+	WarningMessages       []warningFingerprintCount
+	ResourcesByType       map[string]map[string]float64
+	ClassResources        []classResourceCounts
+	PackageChanges        []packageChange
+	RestartedServices     []string
+	EventsByProperty      map[string]map[string]float64
+	MetricGroups          map[string]map[string]float64
+
+	DependencySkippedResources float64
+	DependencyFailures         []dependencyFailure
 }
 
 func (r interpretedReport) collect(ch chan<- prometheus.Metric) {
@@ -127,6 +565,13 @@ func (r interpretedReport) collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(runAtDesc, prometheus.GaugeValue, r.RunAt)
 	ch <- prometheus.MustNewConstMetric(runDurationDesc, prometheus.GaugeValue, r.RunDuration)
 	ch <- prometheus.MustNewConstMetric(runSuccessDesc, prometheus.GaugeValue, r.RunSuccess)
+	ch <- prometheus.MustNewConstMetric(runInfoDesc, prometheus.GaugeValue, 1, r.Info.labelValues()...)
+	ch <- prometheus.MustNewConstMetric(runNoopDesc, prometheus.GaugeValue, r.Noop)
+	ch <- prometheus.MustNewConstMetric(runNoopPendingDesc, prometheus.GaugeValue, r.NoopPending)
+
+	collectOneHot(ch, runStatusDesc, runStatuses, r.Status)
+	collectOneHot(ch, cachedCatalogStatusDesc, cachedCatalogStatuses, r.CachedCatalogStatus)
+	collectOneHot(ch, failureReasonDesc, failureReasons, r.FailureReason)
 
 	for resource, value := range r.RunReportResources {
 		ch <- prometheus.MustNewConstMetric(runResourcesDesc, prometheus.GaugeValue, value, []string{resource}...)
@@ -136,6 +581,12 @@ func (r interpretedReport) collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(runEventsDesc, prometheus.GaugeValue, value, []string{event}...)
 	}
 
+	for property, statuses := range r.EventsByProperty {
+		for status, value := range statuses {
+			ch <- prometheus.MustNewConstMetric(eventsByPropertyDesc, prometheus.GaugeValue, value, property, status)
+		}
+	}
+
 	for change, value := range r.RunReportChanges {
 		ch <- prometheus.MustNewConstMetric(runChangesDesc, prometheus.GaugeValue, value, []string{change}...)
 	}
@@ -144,4 +595,151 @@ func (r interpretedReport) collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(runReportTimeDurationDesc, prometheus.GaugeValue, value, []string{key}...)
 	}
 
+	for resourceType, states := range r.ResourcesByType {
+		for state, value := range states {
+			ch <- prometheus.MustNewConstMetric(resourcesByTypeDesc, prometheus.GaugeValue, value, resourceType, state)
+		}
+	}
+
+	for level, value := range r.LogMessages {
+		ch <- prometheus.MustNewConstMetric(logMessagesDesc, prometheus.GaugeValue, value, level)
+	}
+
+}
+
+// collectOneHot exports every known value of desc's single label, set to 1 for
+// current and 0 otherwise. A current value Puppet added after known was written
+// is exported as well; an empty one, from a report predating the field, is not.
+func collectOneHot(ch chan<- prometheus.Metric, desc *prometheus.Desc, known []string, current string) {
+	for _, value := range known {
+		var metricValue float64
+		if value == current {
+			metricValue = 1
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, metricValue, value)
+	}
+	if current != "" && !slices.Contains(known, current) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, current)
+	}
+}
+
+// collectFailedResources exports up to limit failed resources, and how many
+// were left out.
+func (r interpretedReport) collectFailedResources(ch chan<- prometheus.Metric, limit int) {
+	exported := r.FailedResources[:min(max(limit, 0), len(r.FailedResources))]
+	for _, resource := range exported {
+		ch <- prometheus.MustNewConstMetric(failedResourceDesc, prometheus.GaugeValue, 1, resource.Type, resource.Title)
+	}
+	ch <- prometheus.MustNewConstMetric(failedResourceOverflowDesc, prometheus.GaugeValue, float64(len(r.FailedResources)-len(exported)))
+}
+
+// collectFailedFiles exports the failed resources of up to limit manifest
+// files, those with the most failures first, and how many were left out.
+func (r interpretedReport) collectFailedFiles(ch chan<- prometheus.Metric, limit int) {
+	exported := r.FailedFiles[:min(max(limit, 0), len(r.FailedFiles))]
+	for _, file := range exported {
+		ch <- prometheus.MustNewConstMetric(failedFilesDesc, prometheus.GaugeValue, file.Failed, file.File)
+	}
+	ch <- prometheus.MustNewConstMetric(failedFilesOverflowDesc, prometheus.GaugeValue, float64(len(r.FailedFiles)-len(exported)))
+}
+
+// collectDependencyFailures exports the number of resources skipped because of
+// failed dependencies, the limit root failures that caused most of them, and
+// how many were left out.
+func (r interpretedReport) collectDependencyFailures(ch chan<- prometheus.Metric, limit int) {
+	ch <- prometheus.MustNewConstMetric(dependencySkippedResourcesDesc, prometheus.GaugeValue, r.DependencySkippedResources)
+	exported := r.DependencyFailures[:min(max(limit, 0), len(r.DependencyFailures))]
+	for _, failure := range exported {
+		ch <- prometheus.MustNewConstMetric(dependencyFailureDesc, prometheus.GaugeValue, failure.Skipped, failure.Resource.Type, failure.Resource.Title)
+	}
+	ch <- prometheus.MustNewConstMetric(dependencyFailureOverflowDesc, prometheus.GaugeValue, float64(len(r.DependencyFailures)-len(exported)))
+}
+
+// collectSlowestResources exports the evaluation time of the limit slowest
+// resources.
+func (r interpretedReport) collectSlowestResources(ch chan<- prometheus.Metric, limit int) {
+	for _, timed := range r.ResourceTimes[:min(max(limit, 0), len(r.ResourceTimes))] {
+		ch <- prometheus.MustNewConstMetric(resourceEvaluationDesc, prometheus.GaugeValue, timed.Seconds, timed.Resource.Type, timed.Resource.Title)
+	}
+}
+
+// collectClassResources exports the failed and changed resources of up to limit
+// classes, and how many classes were left out.
+func (r interpretedReport) collectClassResources(ch chan<- prometheus.Metric, limit int) {
+	if limit <= 0 {
+		return
+	}
+	exported := r.ClassResources[:min(limit, len(r.ClassResources))]
+	for _, class := range exported {
+		ch <- prometheus.MustNewConstMetric(classResourcesDesc, prometheus.GaugeValue, class.Failed, class.Class, "failed")
+		ch <- prometheus.MustNewConstMetric(classResourcesDesc, prometheus.GaugeValue, class.Changed, class.Class, "changed")
+	}
+	ch <- prometheus.MustNewConstMetric(classResourcesOverflowDesc, prometheus.GaugeValue, float64(len(r.ClassResources)-len(exported)))
+}
+
+// collectWarningMessages exports the number of warning messages of up to limit
+// fingerprints, the most frequent first.
+func (r interpretedReport) collectWarningMessages(ch chan<- prometheus.Metric, limit int) {
+	if limit <= 0 {
+		return
+	}
+	exported := r.WarningMessages[:min(limit, len(r.WarningMessages))]
+	for _, warning := range exported {
+		ch <- prometheus.MustNewConstMetric(warningMessagesDesc, prometheus.GaugeValue, warning.Count, warning.Fingerprint)
+	}
+	ch <- prometheus.MustNewConstMetric(warningMessagesOverflowDesc, prometheus.GaugeValue, float64(len(r.WarningMessages)-len(exported)))
+}
+
+func (c *Collector) resourceEvaluationBuckets() []float64 {
+	if len(c.ResourceEvaluationBuckets) > 0 {
+		return c.ResourceEvaluationBuckets
+	}
+	return DefaultResourceEvaluationBuckets
+}
+
+// collectResourceEvaluationHistogram exports the distribution of the evaluation
+// time of every resource of the run.
+func (r interpretedReport) collectResourceEvaluationHistogram(ch chan<- prometheus.Metric, buckets []float64, nativeFactor float64) {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:                        resourceEvaluationHistogramName,
+		Help:                        resourceEvaluationHistogramHelp,
+		Buckets:                     buckets,
+		NativeHistogramBucketFactor: nativeFactor,
+	})
+	for _, timed := range r.ResourceTimes {
+		histogram.Observe(timed.Seconds)
+	}
+	ch <- histogram
+}
+
+// collectRestartedServices exports up to limit restarted services, and how
+// many were left out.
+func (r interpretedReport) collectRestartedServices(ch chan<- prometheus.Metric, limit int) {
+	exported := r.RestartedServices[:min(max(limit, 0), len(r.RestartedServices))]
+	for _, service := range exported {
+		ch <- prometheus.MustNewConstMetric(serviceRestartedDesc, prometheus.GaugeValue, 1, service)
+	}
+	ch <- prometheus.MustNewConstMetric(serviceRestartedOverflowDesc, prometheus.GaugeValue, float64(len(r.RestartedServices)-len(exported)))
+}
+
+// collectPackageChanges exports up to limit package changes, and how many
+// there were in total.
+func (r interpretedReport) collectPackageChanges(ch chan<- prometheus.Metric, limit int) {
+	for _, change := range r.PackageChanges[:min(max(limit, 0), len(r.PackageChanges))] {
+		ch <- prometheus.MustNewConstMetric(packageChangeDesc, prometheus.GaugeValue, 1, change.Package, change.From, change.To)
+	}
+	ch <- prometheus.MustNewConstMetric(packageChangesDesc, prometheus.GaugeValue, float64(len(r.PackageChanges)))
+}
+
+// collectMetricGroups exports the metric groups in allow, or every group when
+// allow is empty, except those in deny.
+func (r interpretedReport) collectMetricGroups(ch chan<- prometheus.Metric, allow, deny []string) {
+	for group, values := range r.MetricGroups {
+		if (len(allow) > 0 && !slices.Contains(allow, group)) || slices.Contains(deny, group) {
+			continue
+		}
+		for name, value := range values {
+			ch <- prometheus.MustNewConstMetric(reportMetricDesc, prometheus.GaugeValue, value, group, name)
+		}
+	}
 }
@@ -24,6 +24,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
 	"github.com/prometheus/common/promslog"
 )
 
@@ -261,3 +262,996 @@ transaction_completed: true
 		t.Fatal("expected an error once the report is gone")
 	}
 }
+
+const failedResourcesReport = `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[nginx]:
+    resource_type: Package
+    title: nginx
+    failed: true
+  Exec[reload]:
+    failed: true
+  File[/etc/motd]:
+    resource_type: File
+    title: /etc/motd
+    failed: true
+  Service[ssh]:
+    resource_type: Service
+    title: ssh
+    failed: false
+`
+
+func TestCollectFailedResources(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		limit    int
+		expected string
+	}{
+		{
+			name:  "under the limit",
+			limit: 10,
+			expected: `
+# HELP puppet_last_run_failed_resource Resources that failed during the last Puppet run.
+# TYPE puppet_last_run_failed_resource gauge
+puppet_last_run_failed_resource{title="/etc/motd",type="File"} 1
+puppet_last_run_failed_resource{title="nginx",type="Package"} 1
+puppet_last_run_failed_resource{title="reload",type="Exec"} 1
+# HELP puppet_last_run_failed_resource_overflow Number of failed resources of the last Puppet run left out of puppet_last_run_failed_resource by the limit.
+# TYPE puppet_last_run_failed_resource_overflow gauge
+puppet_last_run_failed_resource_overflow 0
+`,
+		},
+		{
+			// The resources are sorted, so the same ones survive the limit on
+			// every scrape.
+			name:  "over the limit",
+			limit: 2,
+			expected: `
+# HELP puppet_last_run_failed_resource Resources that failed during the last Puppet run.
+# TYPE puppet_last_run_failed_resource gauge
+puppet_last_run_failed_resource{title="reload",type="Exec"} 1
+puppet_last_run_failed_resource{title="/etc/motd",type="File"} 1
+# HELP puppet_last_run_failed_resource_overflow Number of failed resources of the last Puppet run left out of puppet_last_run_failed_resource by the limit.
+# TYPE puppet_last_run_failed_resource_overflow gauge
+puppet_last_run_failed_resource_overflow 1
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Collector{
+				Logger:               promslog.NewNopLogger(),
+				ReportPath:           writeReport(t, failedResourcesReport),
+				FailedResourcesLimit: tc.limit,
+			}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_last_run_failed_resource", "puppet_last_run_failed_resource_overflow"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestParseResourceRef(t *testing.T) {
+	for key, want := range map[string]resourceRef{
+		"File[/etc/motd]":              {Type: "File", Title: "/etc/motd"},
+		"Nginx::Vhost[default]":        {Type: "Nginx::Vhost", Title: "default"},
+		"Exec[echo [brackets] inside]": {Type: "Exec", Title: "echo [brackets] inside"},
+		"not a reference":              {Title: "not a reference"},
+	} {
+		if got := parseResourceRef(key); got != want {
+			t.Errorf("parseResourceRef(%q) = %+v, want %+v", key, got, want)
+		}
+	}
+}
+
+func TestCollectSlowestResources(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Exec[apt-get update]:
+    evaluation_time: 41.5
+  Package[nginx]:
+    evaluation_time: 12.25
+  File[/etc/motd]:
+    evaluation_time: 0.002
+`),
+		SlowestResourcesLimit: 2,
+	}
+
+	expected := `
+# HELP puppet_last_run_resource_evaluation_seconds Evaluation time of the slowest resources of the last Puppet run.
+# TYPE puppet_last_run_resource_evaluation_seconds gauge
+puppet_last_run_resource_evaluation_seconds{title="apt-get update",type="Exec"} 41.5
+puppet_last_run_resource_evaluation_seconds{title="nginx",type="Package"} 12.25
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_resource_evaluation_seconds"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A run may pass isSuccess while logging errors, which is what this metric is
+// there to surface.
+func TestCollectLogMessages(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+logs:
+- level: warning
+  message: Unknown variable '$::osfamily'
+  source: Scope(Class[Main])
+  time: '2021-04-20T22:18:46.000000000+00:00'
+- level: err
+  message: Could not retrieve fact ipaddress
+  source: Puppet
+  time: '2021-04-20T22:18:47.000000000+00:00'
+- level: warning
+  message: Deprecation notice
+  source: Puppet
+  time: '2021-04-20T22:18:48.000000000+00:00'
+- level: notice
+  message: Applied catalog in 12.08 seconds
+  source: Puppet
+  time: '2021-04-20T22:19:02.789529236+00:00'
+`),
+	}
+
+	expected := `
+# HELP puppet_last_run_log_messages Number of log messages of the last Puppet run by level.
+# TYPE puppet_last_run_log_messages gauge
+puppet_last_run_log_messages{level="err"} 1
+puppet_last_run_log_messages{level="notice"} 1
+puppet_last_run_log_messages{level="warning"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_log_messages"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP puppet_last_run_success 1 if the last Puppet run was successful.
+# TYPE puppet_last_run_success gauge
+puppet_last_run_success 1
+`), "puppet_last_run_success"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectNoopRun(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+status: unchanged
+noop: true
+noop_pending: true
+`),
+	}
+
+	expected := `
+# HELP puppet_last_run_noop 1 if the last Puppet run was a noop run.
+# TYPE puppet_last_run_noop gauge
+puppet_last_run_noop 1
+# HELP puppet_last_run_noop_pending 1 if the last Puppet run held back changes because of noop.
+# TYPE puppet_last_run_noop_pending gauge
+puppet_last_run_noop_pending 1
+# HELP puppet_last_run_status Status Puppet recorded for the last run, 1 for the current one.
+# TYPE puppet_last_run_status gauge
+puppet_last_run_status{status="changed"} 0
+puppet_last_run_status{status="failed"} 0
+puppet_last_run_status{status="unchanged"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_noop", "puppet_last_run_noop_pending", "puppet_last_run_status"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A run on a cached catalog still succeeds, which is what hides an unreachable
+// server from puppet_last_run_success.
+func TestCollectCachedCatalogOnFailure(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+cached_catalog_status: on_failure
+`),
+	}
+
+	expected := `
+# HELP puppet_last_run_cached_catalog_status Whether the last Puppet run applied a cached catalog, 1 for the current status.
+# TYPE puppet_last_run_cached_catalog_status gauge
+puppet_last_run_cached_catalog_status{status="explicitly_requested"} 0
+puppet_last_run_cached_catalog_status{status="not_used"} 0
+puppet_last_run_cached_catalog_status{status="on_failure"} 1
+# HELP puppet_last_run_success 1 if the last Puppet run was successful.
+# TYPE puppet_last_run_success gauge
+puppet_last_run_success 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_cached_catalog_status", "puppet_last_run_success"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		server   string
+		expected string
+	}{
+		{
+			name:   "server_used",
+			server: "server_used: compiler-2.example.com:8140",
+			expected: `
+# HELP puppet_last_run_info Identity of the last Puppet run report.
+# TYPE puppet_last_run_info gauge
+puppet_last_run_info{catalog_uuid="3d2737d6-a109-4dfe-859e-2773307fc257",code_id="urn:puppet:code-id:1:abc123;production",environment="production",host="node.example.com",puppet_version="8.4.0",report_format="12",server_used="compiler-2.example.com:8140",transaction_uuid="77d7a293-bbcd-498f-8fa7-5bab45f7d68c"} 1
+`,
+		},
+		{
+			name:   "master_used",
+			server: "master_used: compiler-1.example.com:8140",
+			expected: `
+# HELP puppet_last_run_info Identity of the last Puppet run report.
+# TYPE puppet_last_run_info gauge
+puppet_last_run_info{catalog_uuid="3d2737d6-a109-4dfe-859e-2773307fc257",code_id="urn:puppet:code-id:1:abc123;production",environment="production",host="node.example.com",puppet_version="8.4.0",report_format="12",server_used="compiler-1.example.com:8140",transaction_uuid="77d7a293-bbcd-498f-8fa7-5bab45f7d68c"} 1
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Collector{
+				Logger: promslog.NewNopLogger(),
+				ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+host: node.example.com
+time: '2021-04-20T22:18:45.590110290+00:00'
+configuration_version: 1618957129
+transaction_uuid: 77d7a293-bbcd-498f-8fa7-5bab45f7d68c
+catalog_uuid: 3d2737d6-a109-4dfe-859e-2773307fc257
+code_id: urn:puppet:code-id:1:abc123;production
+report_format: 12
+puppet_version: 8.4.0
+environment: production
+transaction_completed: true
+`+tc.server+"\n"),
+			}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_last_run_info"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollectEnvironmentMismatch(t *testing.T) {
+	report := `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+environment: production
+`
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name:   "same environment",
+			config: "[agent]\nenvironment = production\n",
+			expected: `
+# HELP puppet_environment_mismatch 1 if the last Puppet run applied another environment than the one configured in puppet.conf.
+# TYPE puppet_environment_mismatch gauge
+puppet_environment_mismatch{applied="production",configured="production"} 0
+`,
+		},
+		{
+			// The node classifier overrode the branch environment.
+			name:   "overridden environment",
+			config: "[agent]\nenvironment = feature_x\n",
+			expected: `
+# HELP puppet_environment_mismatch 1 if the last Puppet run applied another environment than the one configured in puppet.conf.
+# TYPE puppet_environment_mismatch gauge
+puppet_environment_mismatch{applied="production",configured="feature_x"} 1
+`,
+		},
+		{
+			name:   "default environment",
+			config: "[main]\nserver = puppet.example.com\n",
+			expected: `
+# HELP puppet_environment_mismatch 1 if the last Puppet run applied another environment than the one configured in puppet.conf.
+# TYPE puppet_environment_mismatch gauge
+puppet_environment_mismatch{applied="production",configured="production"} 0
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "puppet.conf")
+			if err := os.WriteFile(configPath, []byte(tc.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			c := &Collector{Logger: promslog.NewNopLogger(), ReportPath: writeReport(t, report), ConfigPath: configPath}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_environment_mismatch"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// The run state is still exported when the report cannot be read.
+func TestCollectRunStateWithoutReport(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := writeState(statePath, runState{LastRunAt: 1618957125, LastSuccessAt: 1618950000, ConsecutiveFailures: 3}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Collector{Logger: promslog.NewNopLogger(), ReportPath: filepath.Join(dir, "absent.yaml"), StatePath: statePath}
+
+	expected := `
+# HELP puppet_consecutive_failed_runs Number of Puppet runs that failed since the last successful one.
+# TYPE puppet_consecutive_failed_runs gauge
+puppet_consecutive_failed_runs 3
+# HELP puppet_last_successful_run_at_seconds Time of the last successful Puppet run seen by the exporter.
+# TYPE puppet_last_successful_run_at_seconds gauge
+puppet_last_successful_run_at_seconds 1.61895e+09
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_consecutive_failed_runs", "puppet_last_successful_run_at_seconds"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectResourcesByType(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[nginx]:
+    resource_type: Package
+    title: nginx
+    failed: true
+    out_of_sync: true
+  Service[nginx]:
+    resource_type: Service
+    title: nginx
+    skipped: true
+  File[/etc/motd]:
+    resource_type: File
+    title: /etc/motd
+    changed: true
+    out_of_sync: true
+  File[/etc/issue]:
+    resource_type: File
+    title: /etc/issue
+`),
+	}
+
+	expected := `
+# HELP puppet_last_run_resources_by_type Resources of the last Puppet run by resource type and state.
+# TYPE puppet_last_run_resources_by_type gauge
+puppet_last_run_resources_by_type{resource_type="File",state="changed"} 1
+puppet_last_run_resources_by_type{resource_type="File",state="failed"} 0
+puppet_last_run_resources_by_type{resource_type="File",state="out_of_sync"} 1
+puppet_last_run_resources_by_type{resource_type="File",state="skipped"} 0
+puppet_last_run_resources_by_type{resource_type="File",state="total"} 2
+puppet_last_run_resources_by_type{resource_type="Package",state="changed"} 0
+puppet_last_run_resources_by_type{resource_type="Package",state="failed"} 1
+puppet_last_run_resources_by_type{resource_type="Package",state="out_of_sync"} 1
+puppet_last_run_resources_by_type{resource_type="Package",state="skipped"} 0
+puppet_last_run_resources_by_type{resource_type="Package",state="total"} 1
+puppet_last_run_resources_by_type{resource_type="Service",state="changed"} 0
+puppet_last_run_resources_by_type{resource_type="Service",state="failed"} 0
+puppet_last_run_resources_by_type{resource_type="Service",state="out_of_sync"} 0
+puppet_last_run_resources_by_type{resource_type="Service",state="skipped"} 1
+puppet_last_run_resources_by_type{resource_type="Service",state="total"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_resources_by_type"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectClassResources(t *testing.T) {
+	report := `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[nginx]:
+    failed: true
+    containment_path: ['Stage[main]', 'Profile::Nginx', 'Package[nginx]']
+  File[/etc/motd]:
+    changed: true
+    containment_path: ['Stage[main]', 'Profile::Base', 'File[/etc/motd]']
+`
+	for _, tc := range []struct {
+		name     string
+		limit    int
+		expected string
+	}{
+		{
+			// The attribution is opt-in.
+			name:     "disabled",
+			limit:    0,
+			expected: "",
+		},
+		{
+			name:  "over the limit",
+			limit: 1,
+			expected: `
+# HELP puppet_last_run_class_resources Failed and changed resources of the last Puppet run by the first class containing them.
+# TYPE puppet_last_run_class_resources gauge
+puppet_last_run_class_resources{class="Profile::Nginx",state="changed"} 0
+puppet_last_run_class_resources{class="Profile::Nginx",state="failed"} 1
+# HELP puppet_last_run_class_resources_overflow Number of classes of the last Puppet run left out of puppet_last_run_class_resources by the limit.
+# TYPE puppet_last_run_class_resources_overflow gauge
+puppet_last_run_class_resources_overflow 1
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Collector{Logger: promslog.NewNopLogger(), ReportPath: writeReport(t, report), ClassResourcesLimit: tc.limit}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_last_run_class_resources", "puppet_last_run_class_resources_overflow"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollectPackageChanges(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[openssl]:
+    resource_type: Package
+    title: openssl
+    changed: true
+    events:
+    - property: ensure
+      previous_value: 3.0.2-0ubuntu1.14
+      desired_value: latest
+      status: success
+      message: ensure changed '3.0.2-0ubuntu1.14' to '3.0.2-0ubuntu1.15'
+  Package[telnet]:
+    resource_type: Package
+    title: telnet
+    changed: true
+    events:
+    - property: ensure
+      previous_value: 0.17-44build1
+      desired_value: !ruby/sym absent
+      status: success
+  Package[nginx]:
+    resource_type: Package
+    title: nginx
+    events:
+    - property: ensure
+      previous_value: absent
+      desired_value: present
+      status: noop
+  File[/etc/motd]:
+    resource_type: File
+    title: /etc/motd
+    events:
+    - property: ensure
+      previous_value: absent
+      desired_value: file
+      status: success
+`),
+		PackageChangesLimit: 1,
+	}
+
+	expected := `
+# HELP puppet_last_run_package_change Packages the last Puppet run installed, upgraded, downgraded or removed.
+# TYPE puppet_last_run_package_change gauge
+puppet_last_run_package_change{from="3.0.2-0ubuntu1.14",package="openssl",to="3.0.2-0ubuntu1.15"} 1
+# HELP puppet_last_run_package_changes Number of package changes made by the last Puppet run, including those left out of puppet_last_run_package_change by the limit.
+# TYPE puppet_last_run_package_changes gauge
+puppet_last_run_package_changes 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_package_change", "puppet_last_run_package_changes"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectEventsByProperty(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  File[/etc/motd]:
+    events:
+    - property: content
+      status: success
+    - property: mode
+      status: success
+  File[/etc/issue]:
+    events:
+    - property: content
+      status: success
+  File[/etc/hosts]:
+    events:
+    - property: content
+      status: failure
+  Service[nginx]:
+    events:
+    - name: restarted
+      status: success
+`),
+	}
+
+	expected := `
+# HELP puppet_last_run_events_by_property Events of the last Puppet run by changed property and status.
+# TYPE puppet_last_run_events_by_property gauge
+puppet_last_run_events_by_property{property="content",status="failure"} 1
+puppet_last_run_events_by_property{property="content",status="success"} 2
+puppet_last_run_events_by_property{property="mode",status="success"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_events_by_property"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectRestartedServices(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Service[nginx]:
+    resource_type: Service
+    title: nginx
+    events:
+    - name: restarted
+      status: success
+  Service[postgresql]:
+    resource_type: Service
+    title: postgresql
+    events:
+    - name: restarted
+      status: success
+`),
+		ServiceRestartsLimit: 1,
+	}
+
+	expected := `
+# HELP puppet_last_run_service_restarted Services the last Puppet run restarted or refreshed.
+# TYPE puppet_last_run_service_restarted gauge
+puppet_last_run_service_restarted{service="nginx"} 1
+# HELP puppet_last_run_service_restarted_overflow Number of services of the last Puppet run left out of puppet_last_run_service_restarted by the limit.
+# TYPE puppet_last_run_service_restarted_overflow gauge
+puppet_last_run_service_restarted_overflow 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_service_restarted", "puppet_last_run_service_restarted_overflow"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectResourceEvaluationHistogram(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Exec[apt-get update]:
+    evaluation_time: 41.5
+  Package[nginx]:
+    evaluation_time: 0.5
+  File[/etc/motd]:
+    evaluation_time: 0.002
+  File[/etc/issue]:
+    evaluation_time: 0.001
+`),
+		ResourceEvaluationBuckets:      []float64{0.01, 1, 60},
+		ResourceEvaluationNativeFactor: 1.1,
+	}
+
+	expected := `
+# HELP puppet_last_run_resource_evaluation_duration_seconds Distribution of the evaluation time of the resources of the last Puppet run.
+# TYPE puppet_last_run_resource_evaluation_duration_seconds histogram
+puppet_last_run_resource_evaluation_duration_seconds_bucket{le="0.01"} 2
+puppet_last_run_resource_evaluation_duration_seconds_bucket{le="1"} 3
+puppet_last_run_resource_evaluation_duration_seconds_bucket{le="60"} 4
+puppet_last_run_resource_evaluation_duration_seconds_bucket{le="+Inf"} 4
+puppet_last_run_resource_evaluation_duration_seconds_sum 42.003
+puppet_last_run_resource_evaluation_duration_seconds_count 4
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_resource_evaluation_duration_seconds"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scrapers negotiating protobuf get the native buckets as well.
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := promlint.NewWithMetricFamilies(families).Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		if problem.Metric == "puppet_last_run_resource_evaluation_duration_seconds" {
+			t.Errorf("promlint: %s", problem.Text)
+		}
+	}
+	for _, family := range families {
+		if family.GetName() != "puppet_last_run_resource_evaluation_duration_seconds" {
+			continue
+		}
+		if schema := family.GetMetric()[0].GetHistogram().Schema; schema == nil {
+			t.Error("histogram has no native schema")
+		}
+		return
+	}
+	t.Fatal("histogram not gathered")
+}
+
+func TestCollectMetricGroups(t *testing.T) {
+	path := writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+metrics:
+  resources:
+    name: resources
+    values:
+    - - total
+      - Total
+      - 12
+  time:
+    name: time
+    values:
+    - - total
+      - Total
+      - 17.5
+  custom:
+    name: custom
+    values:
+    - - widgets
+      - Widgets
+      - 3
+`)
+
+	for _, tc := range []struct {
+		name     string
+		allow    []string
+		deny     []string
+		expected string
+	}{
+		{
+			name: "all",
+			expected: `
+puppet_last_run_report_metric{group="custom",name="widgets"} 3
+puppet_last_run_report_metric{group="resources",name="total"} 12
+puppet_last_run_report_metric{group="time",name="total"} 17.5
+`,
+		},
+		{
+			name:  "allow",
+			allow: []string{"custom"},
+			expected: `
+puppet_last_run_report_metric{group="custom",name="widgets"} 3
+`,
+		},
+		{
+			name: "deny",
+			deny: []string{"time"},
+			expected: `
+puppet_last_run_report_metric{group="custom",name="widgets"} 3
+puppet_last_run_report_metric{group="resources",name="total"} 12
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Collector{
+				Logger:                    promslog.NewNopLogger(),
+				ReportPath:                path,
+				GenericReportMetrics:      true,
+				GenericReportMetricsAllow: tc.allow,
+				GenericReportMetricsDeny:  tc.deny,
+			}
+			expected := `
+# HELP puppet_last_run_report_metric Value of a metric of the last Puppet run report, by metric group and name.
+# TYPE puppet_last_run_report_metric gauge
+` + tc.expected
+			if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_report_metric"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	c := &Collector{Logger: promslog.NewNopLogger(), ReportPath: path}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(""), "puppet_last_run_report_metric"); err != nil {
+		t.Errorf("disabled: %v", err)
+	}
+}
+
+func TestCollectDependencyFailures(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[nginx]:
+    failed: true
+  File[/etc/motd]:
+    failed: true
+  File[/etc/nginx/nginx.conf]:
+    skipped: true
+  Service[nginx]:
+    skipped: true
+  File[/etc/issue]:
+    skipped: true
+logs:
+- level: notice
+  source: /Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf]
+  message: 'Dependency Package[nginx] has failures: true'
+- level: warning
+  source: /Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf]
+  message: Skipping because of failed dependencies
+- level: notice
+  source: /Stage[main]/Profile::Nginx/Service[nginx]
+  message: 'Dependency File[/etc/nginx/nginx.conf] has failures: false'
+- level: warning
+  source: /Stage[main]/Profile::Nginx/Service[nginx]
+  message: Skipping because of failed dependencies
+- level: notice
+  source: /Stage[main]/Profile::Base/File[/etc/issue]
+  message: 'Dependency File[/etc/motd] has failures: true'
+- level: warning
+  source: /Stage[main]/Profile::Base/File[/etc/issue]
+  message: Skipping because of failed dependencies
+`),
+		DependencyFailuresLimit: 1,
+	}
+
+	expected := `
+# HELP puppet_last_run_dependency_failure Failed resources of the last Puppet run that caused others to be skipped, with the number of resources skipped because of them.
+# TYPE puppet_last_run_dependency_failure gauge
+puppet_last_run_dependency_failure{title="nginx",type="Package"} 2
+# HELP puppet_last_run_dependency_failure_overflow Number of failed resources of the last Puppet run that caused others to be skipped left out of puppet_last_run_dependency_failure by the limit.
+# TYPE puppet_last_run_dependency_failure_overflow gauge
+puppet_last_run_dependency_failure_overflow 1
+# HELP puppet_last_run_dependency_skipped_resources Number of resources the last Puppet run skipped because of failed dependencies.
+# TYPE puppet_last_run_dependency_skipped_resources gauge
+puppet_last_run_dependency_skipped_resources 3
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_dependency_failure", "puppet_last_run_dependency_failure_overflow", "puppet_last_run_dependency_skipped_resources"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectWarningMessages(t *testing.T) {
+	path := writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+logs:
+- level: warning
+  source: Puppet
+  message: 'Unknown variable: ''::osfamily''. (file: /etc/puppetlabs/code/site.pp, line: 3)'
+- level: warning
+  source: Puppet
+  message: 'Unknown variable: ''::osfamily''. (file: /etc/puppetlabs/code/base.pp, line: 8)'
+- level: warning
+  source: Puppet
+  message: 'The function ''hiera'' is deprecated in favor of using ''lookup''.'
+- level: notice
+  source: Puppet
+  message: Applied catalog in 1.23 seconds
+`)
+
+	for _, tc := range []struct {
+		name     string
+		limit    int
+		expected string
+	}{
+		{name: "disabled", limit: 0, expected: ""},
+		{
+			name:  "limited",
+			limit: 1,
+			expected: `
+# HELP puppet_last_run_warning_messages Number of warning messages of the last Puppet run by message fingerprint, with numbers, paths and quoted values other than identifiers left out.
+# TYPE puppet_last_run_warning_messages gauge
+puppet_last_run_warning_messages{fingerprint="Unknown variable: '::osfamily'. (file: <path>, line: <n>)"} 2
+# HELP puppet_last_run_warning_messages_overflow Number of warning fingerprints of the last Puppet run left out of puppet_last_run_warning_messages by the limit.
+# TYPE puppet_last_run_warning_messages_overflow gauge
+puppet_last_run_warning_messages_overflow 1
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Collector{
+				Logger:                   promslog.NewNopLogger(),
+				ReportPath:               path,
+				WarningFingerprintsLimit: tc.limit,
+			}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_last_run_warning_messages", "puppet_last_run_warning_messages_overflow"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollectFailedFiles(t *testing.T) {
+	c := &Collector{
+		Logger: promslog.NewNopLogger(),
+		ReportPath: writeReport(t, `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45.590110290+00:00'
+transaction_completed: true
+resource_statuses:
+  Package[nginx]:
+    failed: true
+    file: /etc/puppetlabs/code/environments/production/modules/profile/manifests/nginx.pp
+    line: 4
+  Service[nginx]:
+    failed: true
+    file: /etc/puppetlabs/code/environments/production/modules/profile/manifests/nginx.pp
+    line: 12
+  File[/etc/motd]:
+    failed: true
+    file: /etc/puppetlabs/code/environments/production/modules/profile/manifests/base.pp
+    line: 3
+  File[/etc/issue]:
+    file: /etc/puppetlabs/code/environments/production/modules/profile/manifests/base.pp
+    line: 9
+  Exec[generated]:
+    failed: true
+`),
+		FailedFilesLimit: 1,
+	}
+
+	expected := `
+# HELP puppet_last_run_failed_resources_by_file Number of resources that failed during the last Puppet run by the manifest file declaring them.
+# TYPE puppet_last_run_failed_resources_by_file gauge
+puppet_last_run_failed_resources_by_file{file="/etc/puppetlabs/code/environments/production/modules/profile/manifests/nginx.pp"} 2
+# HELP puppet_last_run_failed_resources_by_file_overflow Number of manifest files of the last Puppet run left out of puppet_last_run_failed_resources_by_file by the limit.
+# TYPE puppet_last_run_failed_resources_by_file_overflow gauge
+puppet_last_run_failed_resources_by_file_overflow 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "puppet_last_run_failed_resources_by_file", "puppet_last_run_failed_resources_by_file_overflow"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectNextRun(t *testing.T) {
+	// The run started at 2021-04-20T22:18:45Z.
+	report := `--- !ruby/object:Puppet::Transaction::Report
+configuration_version: 1618957129
+time: '2021-04-20T22:18:45+00:00'
+transaction_completed: true
+`
+
+	// The same run, lasting an hour.
+	longReport := report + `metrics:
+  time:
+    name: time
+    values:
+    - - total
+      - Total
+      - 3600
+`
+
+	for _, tc := range []struct {
+		name     string
+		report   string
+		config   string
+		noConfig bool
+		now      time.Time
+		expected string
+	}{
+		{
+			name:   "default runinterval",
+			config: "[main]\n",
+			now:    time.Date(2021, 4, 20, 22, 30, 0, 0, time.UTC),
+			expected: `
+# HELP puppet_next_run_expected_at_seconds Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.
+# TYPE puppet_next_run_expected_at_seconds gauge
+puppet_next_run_expected_at_seconds 1.618958925e+09
+# HELP puppet_run_overdue_seconds Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.
+# TYPE puppet_run_overdue_seconds gauge
+puppet_run_overdue_seconds 0
+`,
+		},
+		{
+			name:   "overdue",
+			config: "[agent]\nruninterval = 10m\n",
+			now:    time.Date(2021, 4, 20, 23, 0, 0, 0, time.UTC),
+			expected: `
+# HELP puppet_next_run_expected_at_seconds Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.
+# TYPE puppet_next_run_expected_at_seconds gauge
+puppet_next_run_expected_at_seconds 1.618957725e+09
+# HELP puppet_run_overdue_seconds Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.
+# TYPE puppet_run_overdue_seconds gauge
+puppet_run_overdue_seconds 1875
+`,
+		},
+		{
+			name:   "splay",
+			config: "[agent]\nruninterval = 4h\nsplay = true\nsplaylimit = 15m\n",
+			now:    time.Date(2021, 4, 21, 2, 30, 0, 0, time.UTC),
+			expected: `
+# HELP puppet_next_run_expected_at_seconds Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.
+# TYPE puppet_next_run_expected_at_seconds gauge
+puppet_next_run_expected_at_seconds 1.618972425e+09
+# HELP puppet_run_overdue_seconds Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.
+# TYPE puppet_run_overdue_seconds gauge
+puppet_run_overdue_seconds 0
+`,
+		},
+		{
+			// Without puppet.conf the default runinterval applies.
+			name:     "unreadable puppet.conf",
+			noConfig: true,
+			now:      time.Date(2021, 4, 20, 22, 30, 0, 0, time.UTC),
+			expected: `
+# HELP puppet_next_run_expected_at_seconds Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.
+# TYPE puppet_next_run_expected_at_seconds gauge
+puppet_next_run_expected_at_seconds 1.618958925e+09
+# HELP puppet_run_overdue_seconds Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.
+# TYPE puppet_run_overdue_seconds gauge
+puppet_run_overdue_seconds 0
+`,
+		},
+		{
+			// The next run starts once a run longer than runinterval ends.
+			name:   "long run",
+			report: longReport,
+			config: "[agent]\nruninterval = 10m\n",
+			now:    time.Date(2021, 4, 20, 23, 30, 0, 0, time.UTC),
+			expected: `
+# HELP puppet_next_run_expected_at_seconds Time the next Puppet run is expected to start at the latest, from the last run and the runinterval, splay and splaylimit of puppet.conf.
+# TYPE puppet_next_run_expected_at_seconds gauge
+puppet_next_run_expected_at_seconds 1.618960725e+09
+# HELP puppet_run_overdue_seconds Time elapsed since the next Puppet run was expected to start at the latest, 0 until then.
+# TYPE puppet_run_overdue_seconds gauge
+puppet_run_overdue_seconds 675
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "puppet.conf")
+			if !tc.noConfig {
+				if err := os.WriteFile(configPath, []byte(tc.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			runReport := report
+			if tc.report != "" {
+				runReport = tc.report
+			}
+			c := &Collector{
+				Logger:     promslog.NewNopLogger(),
+				ReportPath: writeReport(t, runReport),
+				ConfigPath: configPath,
+				now:        func() time.Time { return tc.now },
+			}
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.expected), "puppet_next_run_expected_at_seconds", "puppet_run_overdue_seconds"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
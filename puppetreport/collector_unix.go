@@ -19,6 +19,9 @@ package puppetreport
 // DefaultReportPath is the default location of the puppet agent last run report file on unix.
 const DefaultReportPath = "/opt/puppetlabs/puppet/cache/state/last_run_report.yaml"
 
+// DefaultStatePath is the default location of the exporter's own run state file on unix.
+const DefaultStatePath = "/var/lib/puppet-agent-exporter/state.json"
+
 func (c *Collector) reportPath() string {
 	if c.ReportPath != "" {
 		return c.ReportPath
@@ -0,0 +1,135 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// dependencySkips maps the resources Puppet skipped because of failed
+// dependencies to the dependencies it reported as failed for them. Puppet logs
+// "Skipping because of failed dependencies" from each such resource, after a
+// "Dependency Type[title] has failures: true" message for each failed
+// dependency, or "has failures: false" for one that was itself skipped, so
+// both are kept for dependencyRoots to tell them apart. Resources are keyed as in the resource statuses when the log
+// source matches one of them, and by log source otherwise. As the skip is
+// logged at warning level and the dependencies at notice level, a resource
+// whose status is skipped also counts when only its dependencies were logged,
+// and one whose status is not skipped does not.
+func (r runReport) dependencySkips() map[string][]string {
+	skipped := make(map[string]bool)
+	dependencies := make(map[string][]string)
+	for _, log := range r.Logs {
+		if log.Message == "Skipping because of failed dependencies" {
+			skipped[r.statusKey(log.Source)] = true
+			continue
+		}
+		dependency, ok := strings.CutPrefix(log.Message, "Dependency ")
+		if !ok {
+			continue
+		}
+		dependency, _, ok = strings.Cut(dependency, " has failures: ")
+		if ok {
+			key := r.statusKey(log.Source)
+			dependencies[key] = append(dependencies[key], dependency)
+		}
+	}
+
+	for key := range dependencies {
+		if status, ok := r.ResourceStatuses[key]; ok && status.Skipped {
+			skipped[key] = true
+		}
+	}
+
+	result := make(map[string][]string, len(skipped))
+	for key := range skipped {
+		if status, ok := r.ResourceStatuses[key]; ok && !status.Skipped {
+			continue
+		}
+		result[key] = dependencies[key]
+	}
+	return result
+}
+
+// statusKey returns the key of the resource status a log source such as
+// /Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf] comes from, or the
+// source itself when it matches none. Titles may contain slashes, so every
+// suffix following one is tried, longest first.
+func (r runReport) statusKey(source string) string {
+	for i, c := range source {
+		if c != '/' {
+			continue
+		}
+		if _, ok := r.ResourceStatuses[source[i+1:]]; ok {
+			return source[i+1:]
+		}
+	}
+	return source
+}
+
+// dependencyFailure is a failed resource that caused others to be skipped.
+type dependencyFailure struct {
+	Resource resourceRef
+	Skipped  float64
+}
+
+// dependencyFailures returns the root failures behind the skipped resources,
+// with the number of resources skipped because of each of them, directly or
+// through other skipped resources. The failures are sorted by that number, so
+// that a limit applied to them keeps those with the largest blast radius.
+func (r runReport) dependencyFailures(skips map[string][]string) []dependencyFailure {
+	counts := make(map[string]float64)
+	for key := range skips {
+		roots := make(map[string]bool)
+		r.dependencyRoots(skips, key, make(map[string]bool), roots)
+		for root := range roots {
+			counts[root]++
+		}
+	}
+
+	var result []dependencyFailure
+	for root, count := range counts {
+		result = append(result, dependencyFailure{Resource: parseResourceRef(root), Skipped: count})
+	}
+	slices.SortFunc(result, func(a, b dependencyFailure) int {
+		if c := cmp.Compare(b.Skipped, a.Skipped); c != 0 {
+			return c
+		}
+		return compareResourceRefs(a.Resource, b.Resource)
+	})
+	return result
+}
+
+// dependencyRoots adds to roots the failed dependencies of key that were not
+// themselves skipped, following those that were. A dependency with a status
+// is only a root when that status failed or failed to restart.
+func (r runReport) dependencyRoots(skips map[string][]string, key string, visited, roots map[string]bool) {
+	if visited[key] {
+		return
+	}
+	visited[key] = true
+	for _, dependency := range skips[key] {
+		if _, ok := skips[dependency]; ok {
+			r.dependencyRoots(skips, dependency, visited, roots)
+			continue
+		}
+		if status, ok := r.ResourceStatuses[dependency]; ok && !status.Failed && !status.FailedToRestart {
+			continue
+		}
+		roots[dependency] = true
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyFailures(t *testing.T) {
+	report := runReport{
+		ResourceStatuses: map[string]resourceStatus{
+			"Package[nginx]":              {Failed: true},
+			"File[/etc/nginx/nginx.conf]": {Skipped: true},
+			"Service[nginx]":              {Skipped: true},
+			"Exec[reload]":                {Skipped: true},
+			"File[/etc/motd]":             {Failed: true},
+			"File[/etc/issue]":            {Skipped: true},
+			"Service[app]":                {FailedToRestart: true},
+			"Exec[notify]":                {Skipped: true},
+			"File[/etc/hosts]":            {},
+		},
+		Logs: []puppetUtilLog{
+			{Source: "/Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf]", Message: "Dependency Package[nginx] has failures: true"},
+			{Source: "/Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf]", Message: "Skipping because of failed dependencies"},
+			// Puppet logs a dependency that was skipped as having no failures.
+			{Source: "/Stage[main]/Profile::Nginx/Service[nginx]", Message: "Dependency File[/etc/nginx/nginx.conf] has failures: false"},
+			{Source: "/Stage[main]/Profile::Nginx/Service[nginx]", Message: "Skipping because of failed dependencies"},
+			{Source: "/Stage[main]/Profile::Nginx/Exec[reload]", Message: "Dependency Service[nginx] has failures: false"},
+			{Source: "/Stage[main]/Profile::Nginx/Exec[reload]", Message: "Dependency File[/etc/motd] has failures: true"},
+			{Source: "/Stage[main]/Profile::Nginx/Exec[reload]", Message: "Skipping because of failed dependencies"},
+			{Source: "/Stage[main]/Profile::Base/File[/etc/issue]", Message: "Dependency File[/etc/motd] has failures: true"},
+			{Source: "/Stage[main]/Profile::Base/File[/etc/issue]", Message: "Skipping because of failed dependencies"},
+			// The skip itself was not logged, the status tells it.
+			{Source: "/Stage[main]/Profile::App/Exec[notify]", Message: "Dependency Service[app] has failures: true"},
+			// A dependency whose status did not fail is not a root failure.
+			{Source: "/Stage[main]/Profile::App/Exec[notify]", Message: "Dependency File[/etc/hosts] has failures: true"},
+			{Source: "Puppet", Message: "Applied catalog in 1.23 seconds"},
+		},
+	}
+
+	skips := report.dependencySkips()
+	if len(skips) != 5 {
+		t.Errorf("dependencySkips() = %v, want 5 resources", skips)
+	}
+
+	// Skips through other skipped resources count towards their root failure.
+	want := []dependencyFailure{
+		{Resource: resourceRef{Type: "Package", Title: "nginx"}, Skipped: 3},
+		{Resource: resourceRef{Type: "File", Title: "/etc/motd"}, Skipped: 2},
+		{Resource: resourceRef{Type: "Service", Title: "app"}, Skipped: 1},
+	}
+	if got := report.dependencyFailures(skips); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyFailures() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// resourceEvent is a change Puppet made, or tried to make, to a property of a
+// resource.
+type resourceEvent struct {
+	Name          string     `yaml:"name"`
+	Property      string     `yaml:"property"`
+	PreviousValue eventValue `yaml:"previous_value"`
+	DesiredValue  eventValue `yaml:"desired_value"`
+	Status        string     `yaml:"status"`
+	Message       string     `yaml:"message"`
+}
+
+// eventValue holds a property value of an event. Depending on the property it
+// may be a string, a number, a list or missing, so it is kept in its textual
+// form, with list items joined by commas.
+type eventValue string
+
+func (v *eventValue) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw any
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*v = eventValue(formatEventValue(raw))
+	return nil
+}
+
+func formatEventValue(raw any) string {
+	switch value := raw.(type) {
+	case nil:
+		return ""
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, formatEventValue(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// eventsByProperty counts the events of the run by property and status. Events
+// without a property, such as refreshes, are left out.
+func (r runReport) eventsByProperty() map[string]map[string]float64 {
+	result := make(map[string]map[string]float64)
+	for _, status := range r.ResourceStatuses {
+		for _, event := range status.Events {
+			if event.Property == "" {
+				continue
+			}
+			counts, ok := result[event.Property]
+			if !ok {
+				counts = make(map[string]float64)
+				result[event.Property] = counts
+			}
+			counts[event.Status]++
+		}
+	}
+	return result
+}
+
+// packageChange is a package whose version Puppet changed.
+type packageChange struct {
+	Package string
+	From    string
+	To      string
+}
+
+var (
+	// ensureChangedPattern matches the message of a package ensure event
+	// giving the versions it changed between, "ensure changed '1.0-1' to
+	// '1.1-1'".
+	ensureChangedPattern = regexp.MustCompile(`changed '([^']*)' to '([^']*)'`)
+	versionPattern       = regexp.MustCompile(`^\d[\w.:+~-]*$`)
+)
+
+// packageStates are the ensure values besides versions that tell what became
+// of a package.
+var packageStates = []string{"absent", "purged", "held"}
+
+// packageVersions returns the versions a package ensure event changed the
+// package between. The desired value is the ensure of the manifest, which may
+// be present, installed or latest rather than a version, so the versions are
+// taken from the event message when it gives them, and from the event values
+// only when they are versions or package states. Others are left empty.
+func packageVersions(event resourceEvent) (from, to string) {
+	if match := ensureChangedPattern.FindStringSubmatch(event.Message); match != nil {
+		return packageVersion(match[1]), packageVersion(match[2])
+	}
+	return packageVersion(string(event.PreviousValue)), packageVersion(string(event.DesiredValue))
+}
+
+func packageVersion(value string) string {
+	if versionPattern.MatchString(value) || slices.Contains(packageStates, value) {
+		return value
+	}
+	return ""
+}
+
+// packageChanges returns the packages whose ensure property Puppet changed
+// during the run, sorted by name. Noop and failed events changed nothing, so
+// they are left out.
+func (r runReport) packageChanges() []packageChange {
+	var result []packageChange
+	for key, status := range r.ResourceStatuses {
+		ref := status.ref(key)
+		if ref.Type != "Package" {
+			continue
+		}
+		for _, event := range status.Events {
+			if event.Property == "ensure" && event.Status == "success" {
+				from, to := packageVersions(event)
+				result = append(result, packageChange{Package: ref.Title, From: from, To: to})
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b packageChange) int {
+		return strings.Compare(a.Package, b.Package)
+	})
+	return result
+}
+
+// restartedServices returns the titles of the Service resources Puppet
+// restarted, or refreshed, during the run, sorted. A refresh shows up as a
+// restarted event in the resource status, and Puppet also logs it from the
+// resource as "Triggered 'refresh' from N events"; either is enough.
+func (r runReport) restartedServices() []string {
+	restarted := make(map[string]bool)
+	for key, status := range r.ResourceStatuses {
+		ref := status.ref(key)
+		if ref.Type != "Service" {
+			continue
+		}
+		for _, event := range status.Events {
+			if event.Name == "restarted" && event.Status == "success" {
+				restarted[ref.Title] = true
+			}
+		}
+	}
+	for _, log := range r.Logs {
+		if !strings.HasPrefix(log.Message, "Triggered 'refresh' from") {
+			continue
+		}
+		if service, ok := serviceFromLogSource(log.Source); ok {
+			restarted[service] = true
+		}
+	}
+
+	var result []string
+	for service := range restarted {
+		result = append(result, service)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// serviceFromLogSource returns the service title of a log source such as
+// /Stage[main]/Profile::Nginx/Service[nginx].
+func serviceFromLogSource(source string) (string, bool) {
+	start := strings.LastIndex(source, "Service[")
+	if start < 0 || (start > 0 && source[start-1] != '/') || !strings.HasSuffix(source, "]") {
+		return "", false
+	}
+	return source[start+len("Service[") : len(source)-1], true
+}
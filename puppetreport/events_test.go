@@ -0,0 +1,109 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"slices"
+	"testing"
+
+	"go.yaml.in/yaml/v2"
+)
+
+func TestEventValue(t *testing.T) {
+	for _, tc := range []struct {
+		yaml string
+		want eventValue
+	}{
+		{yaml: "value: 1.2.3", want: "1.2.3"},
+		{yaml: "value: '0644'", want: "0644"},
+		{yaml: "value: 42", want: "42"},
+		{yaml: "value: !ruby/sym absent", want: "absent"},
+		{yaml: "value: [web, admin]", want: "web,admin"},
+		{yaml: "value:", want: ""},
+	} {
+		var decoded struct {
+			Value eventValue `yaml:"value"`
+		}
+		if err := yaml.Unmarshal([]byte(tc.yaml), &decoded); err != nil {
+			t.Fatalf("%s: %v", tc.yaml, err)
+		}
+		if decoded.Value != tc.want {
+			t.Errorf("%s: decoded %q, want %q", tc.yaml, decoded.Value, tc.want)
+		}
+	}
+}
+
+func TestPackageVersions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		event    resourceEvent
+		from, to string
+	}{
+		{
+			name:  "upgrade to latest",
+			event: resourceEvent{PreviousValue: "3.0.2-0ubuntu1.14", DesiredValue: "latest", Message: "ensure changed '3.0.2-0ubuntu1.14' to '3.0.2-0ubuntu1.15'"},
+			from:  "3.0.2-0ubuntu1.14",
+			to:    "3.0.2-0ubuntu1.15",
+		},
+		{
+			name:  "pinned version with an epoch",
+			event: resourceEvent{PreviousValue: "1:2.3.4-1", DesiredValue: "1:2.3.5-1"},
+			from:  "1:2.3.4-1",
+			to:    "1:2.3.5-1",
+		},
+		{
+			name:  "install",
+			event: resourceEvent{PreviousValue: "purged", DesiredValue: "present", Message: "created"},
+			from:  "purged",
+			to:    "",
+		},
+		{
+			name:  "removal",
+			event: resourceEvent{PreviousValue: "0.17-44build1", DesiredValue: "absent", Message: "removed"},
+			from:  "0.17-44build1",
+			to:    "absent",
+		},
+	} {
+		if from, to := packageVersions(tc.event); from != tc.from || to != tc.to {
+			t.Errorf("%s: packageVersions() = %q, %q, want %q, %q", tc.name, from, to, tc.from, tc.to)
+		}
+	}
+}
+
+func TestRestartedServices(t *testing.T) {
+	report := runReport{
+		ResourceStatuses: map[string]resourceStatus{
+			"Service[postgresql]": {ResourceType: "Service", Title: "postgresql", Events: []resourceEvent{
+				{Name: "restarted", Status: "success"},
+			}},
+			"Service[cron]": {ResourceType: "Service", Title: "cron", Events: []resourceEvent{
+				{Name: "restarted", Status: "noop"},
+			}},
+			"Exec[reload]": {ResourceType: "Exec", Title: "reload", Events: []resourceEvent{
+				{Name: "restarted", Status: "success"},
+			}},
+		},
+		Logs: []puppetUtilLog{
+			{Level: "notice", Source: "/Stage[main]/Profile::Nginx/Service[nginx]", Message: "Triggered 'refresh' from 1 event"},
+			{Level: "notice", Source: "/Stage[main]/Profile::Nginx/File[/etc/nginx/nginx.conf]/content", Message: "content changed '{sha256}ab' to '{sha256}cd'"},
+			{Level: "notice", Source: "/Stage[main]/Main/Exec[Service[foo]]", Message: "Triggered 'refresh' from 1 event"},
+		},
+	}
+
+	want := []string{"nginx", "postgresql"}
+	if got := report.restartedServices(); !slices.Equal(got, want) {
+		t.Errorf("restartedServices() = %q, want %q", got, want)
+	}
+}
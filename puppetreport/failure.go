@@ -0,0 +1,125 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import "strings"
+
+// failureReasons are the reasons a failed run may be attributed to.
+var failureReasons = []string{
+	"catalog_retrieval",
+	"catalog_compilation",
+	"ssl",
+	"dependency_failed",
+	"resource_failed",
+	"transaction_incomplete",
+	"unknown",
+}
+
+// failureLogPatterns maps the reasons recognised from the agent's own error
+// messages to the lowercased fragments of Puppet's well-known messages for
+// them, in the order they are tried. Puppet wraps SSL and compilation errors in
+// its catalog retrieval message, so those come first, and a run that got no
+// catalog never reached its resources.
+var failureLogPatterns = []struct {
+	reason   string
+	patterns []string
+}{
+	{
+		reason: "ssl",
+		patterns: []string{
+			"ssl_connect",
+			"certificate verify failed",
+			"certificate revoked",
+			"could not request certificate",
+			"does not match the agent's private key",
+		},
+	},
+	{
+		reason: "catalog_compilation",
+		patterns: []string{
+			"error 500 on server",
+			"server error:",
+		},
+	},
+	{
+		reason: "catalog_retrieval",
+		patterns: []string{
+			"could not retrieve catalog",
+			"failed to open tcp connection",
+			"connection refused",
+		},
+	},
+}
+
+// dependencyFailurePatterns are the lowercased fragments of the message Puppet
+// logs for a resource it skipped as one of its dependencies failed.
+var dependencyFailurePatterns = []string{
+	"skipping because of failed dependencies",
+}
+
+// agentLogSource is the source of the messages the agent logs itself, rather
+// than on behalf of a resource.
+const agentLogSource = "Puppet"
+
+// failureReason classifies why a run failed, or returns "" for a successful
+// one. The catalog and SSL patterns are only looked for in the agent's own
+// errors, as a resource failing with similar output, such as an Exec reaching
+// a service, is a resource failure.
+func (r runReport) failureReason(success float64, resources map[string]float64) string {
+	if success == 1 {
+		return ""
+	}
+
+	for _, match := range failureLogPatterns {
+		if logsContain(r.agentErrors(), match.patterns) {
+			return match.reason
+		}
+	}
+
+	switch {
+	case resources["failed"] != 0 || resources["failed_to_restart"] != 0:
+		return "resource_failed"
+	case logsContain(r.Logs, dependencyFailurePatterns):
+		return "dependency_failed"
+	case !r.TransactionCompleted:
+		return "transaction_incomplete"
+	}
+	return "unknown"
+}
+
+// agentErrors returns the err logs of the agent itself.
+func (r runReport) agentErrors() []puppetUtilLog {
+	var errors []puppetUtilLog
+	for _, log := range r.Logs {
+		if log.Level == "err" && log.Source == agentLogSource {
+			errors = append(errors, log)
+		}
+	}
+	return errors
+}
+
+// logsContain reports whether any of logs has a message containing one of
+// patterns, ignoring case.
+func logsContain(logs []puppetUtilLog, patterns []string) bool {
+	for _, log := range logs {
+		message := strings.ToLower(log.Message)
+		for _, pattern := range patterns {
+			if strings.Contains(message, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
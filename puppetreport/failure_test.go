@@ -0,0 +1,101 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import "testing"
+
+func TestFailureReason(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		report    runReport
+		resources map[string]float64
+		want      string
+	}{
+		{
+			name:   "successful run",
+			report: runReport{TransactionCompleted: true},
+			want:   "",
+		},
+		{
+			name: "server unreachable",
+			report: runReport{Logs: []puppetUtilLog{
+				{Level: "err", Source: "Puppet", Message: "Could not retrieve catalog from remote server: Failed to open TCP connection to puppet:8140 (Connection refused - connect(2) for \"puppet\" port 8140)"},
+			}},
+			want: "catalog_retrieval",
+		},
+		{
+			name: "compilation error",
+			report: runReport{Logs: []puppetUtilLog{
+				{Level: "err", Source: "Puppet", Message: "Could not retrieve catalog from remote server: Error 500 on SERVER: Server Error: Evaluation Error: Unknown variable: '$foo'"},
+				{Level: "warning", Message: "Not using cache on failed catalog"},
+			}},
+			want: "catalog_compilation",
+		},
+		{
+			name: "certificate error",
+			report: runReport{Logs: []puppetUtilLog{
+				{Level: "err", Source: "Puppet", Message: "Could not retrieve catalog from remote server: SSL_connect returned=1 errno=0 state=error: certificate verify failed"},
+			}},
+			want: "ssl",
+		},
+		{
+			name: "cascading failure",
+			report: runReport{TransactionCompleted: true, Logs: []puppetUtilLog{
+				{Level: "err", Message: "Execution of '/usr/bin/apt-get -q -y install nginx' returned 100"},
+				{Level: "warning", Message: "Skipping because of failed dependencies"},
+			}},
+			resources: map[string]float64{"failed": 1, "skipped": 1},
+			want:      "resource_failed",
+		},
+		{
+			name: "interrupted after failed dependencies",
+			report: runReport{TransactionCompleted: false, Logs: []puppetUtilLog{
+				{Level: "notice", Source: "/Stage[main]/Profile::Nginx/Service[nginx]", Message: "Skipping because of failed dependencies"},
+			}},
+			resources: map[string]float64{"skipped": 1},
+			want:      "dependency_failed",
+		},
+		{
+			name: "resource reporting a refused connection",
+			report: runReport{TransactionCompleted: true, Logs: []puppetUtilLog{
+				{Level: "err", Source: "/Stage[main]/Profile::App/Exec[migrate]/returns", Message: "change from 'notrun' to ['0'] failed: psql: could not connect to server: Connection refused"},
+			}},
+			resources: map[string]float64{"failed": 1},
+			want:      "resource_failed",
+		},
+		{
+			name:      "single resource failure",
+			report:    runReport{TransactionCompleted: true},
+			resources: map[string]float64{"failed": 1},
+			want:      "resource_failed",
+		},
+		{
+			name:   "interrupted run",
+			report: runReport{TransactionCompleted: false},
+			want:   "transaction_incomplete",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resources := tc.resources
+			if resources == nil {
+				resources = map[string]float64{}
+			}
+			success := tc.report.isSuccess(resources)
+			if got := tc.report.failureReason(success, resources); got != tc.want {
+				t.Errorf("failureReason() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -15,11 +15,14 @@
 package puppetreport
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"math"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,10 +30,22 @@ import (
 )
 
 type runReport struct {
+	Host                 string                      `yaml:"host"`
+	Environment          string                      `yaml:"environment"`
+	PuppetVersion        string                      `yaml:"puppet_version"`
+	CatalogUUID          string                      `yaml:"catalog_uuid"`
+	TransactionUUID      string                      `yaml:"transaction_uuid"`
+	CodeID               string                      `yaml:"code_id"`
+	ServerUsed           string                      `yaml:"server_used"`
+	MasterUsed           string                      `yaml:"master_used"`
 	ConfigurationVersion catalogVersion              `yaml:"configuration_version"`
 	Time                 time.Time                   `yaml:"time"`
 	TransactionCompleted bool                        `yaml:"transaction_completed"`
 	ReportFormat         int                         `yaml:"report_format"`
+	Status               string                      `yaml:"status"`
+	Noop                 bool                        `yaml:"noop"`
+	NoopPending          bool                        `yaml:"noop_pending"`
+	CachedCatalogStatus  string                      `yaml:"cached_catalog_status"`
 	ResourceStatuses     map[string]resourceStatus   `yaml:"resource_statuses"`
 	Metrics              map[string]puppetUtilMetric `yaml:"metrics"`
 	Logs                 []puppetUtilLog             `yaml:"logs"`
@@ -38,6 +53,8 @@ type runReport struct {
 
 func (r runReport) interpret() interpretedReport {
 	resourcesMetrics := r.resourcesMetrics()
+	success := r.isSuccess(resourcesMetrics)
+	dependencySkips := r.dependencySkips()
 	return interpretedReport{
 		RunAt:                 asUnixSeconds(r.Time),
 		RunDuration:           r.totalDuration(),
@@ -46,7 +63,27 @@ func (r runReport) interpret() interpretedReport {
 		RunReportEvents:       r.eventsMetrics(),
 		RunReportChanges:      r.changesMetrics(),
 		RunReportTimeDuration: r.reportTimeDurationMetrics(),
-		RunSuccess:            r.isSuccess(resourcesMetrics),
+		RunSuccess:            success,
+		FailureReason:         r.failureReason(success, resourcesMetrics),
+		Status:                r.Status,
+		Noop:                  asFloat(r.Noop),
+		NoopPending:           asFloat(r.NoopPending),
+		CachedCatalogStatus:   r.CachedCatalogStatus,
+		Info:                  r.info(),
+		FailedResources:       r.failedResources(),
+		FailedFiles:           r.failedFiles(),
+		ResourceTimes:         r.resourceTimes(),
+		LogMessages:           r.logLevels(),
+		WarningMessages:       r.warningMessages(),
+		ResourcesByType:       r.resourcesByType(),
+		ClassResources:        r.classResources(),
+		PackageChanges:        r.packageChanges(),
+		RestartedServices:     r.restartedServices(),
+		EventsByProperty:      r.eventsByProperty(),
+		MetricGroups:          r.metricGroups(),
+
+		DependencySkippedResources: float64(len(dependencySkips)),
+		DependencyFailures:         r.dependencyFailures(dependencySkips),
 	}
 }
 
@@ -89,6 +126,50 @@ func asUnixSeconds(t time.Time) float64 {
 	return float64(t.Unix()) + (float64(t.Nanosecond()) / 1e+9)
 }
 
+// info returns the identity fields of the report.
+func (r runReport) info() reportInfo {
+	info := reportInfo{
+		Host:            r.Host,
+		Environment:     r.Environment,
+		PuppetVersion:   r.PuppetVersion,
+		CatalogUUID:     r.CatalogUUID,
+		TransactionUUID: r.TransactionUUID,
+		CodeID:          r.CodeID,
+		ServerUsed:      r.ServerUsed,
+	}
+	if r.ReportFormat != 0 {
+		info.ReportFormat = strconv.Itoa(r.ReportFormat)
+	}
+	// Puppet 5 and earlier recorded the server as master_used.
+	if info.ServerUsed == "" {
+		info.ServerUsed = r.MasterUsed
+	}
+	return info
+}
+
+// reportInfo holds the identity fields of a report, exported as labels.
+type reportInfo struct {
+	Host            string
+	Environment     string
+	PuppetVersion   string
+	ReportFormat    string
+	CatalogUUID     string
+	TransactionUUID string
+	CodeID          string
+	ServerUsed      string
+}
+
+func (i reportInfo) labelValues() []string {
+	return []string{i.Host, i.Environment, i.PuppetVersion, i.ReportFormat, i.CatalogUUID, i.TransactionUUID, i.CodeID, i.ServerUsed}
+}
+
+func asFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // totalDuration returns the total run duration, or NaN when the report does not
 // carry one. A negative duration would otherwise be indistinguishable from a
 // real measurement.
@@ -122,6 +203,16 @@ func (r runReport) metricValues(name string) map[string]float64 {
 	return metrics.Values()
 }
 
+// metricGroups returns the values of every puppet metric group of the report,
+// including those added by newer Puppet versions or custom report processors.
+func (r runReport) metricGroups() map[string]map[string]float64 {
+	result := make(map[string]map[string]float64, len(r.Metrics))
+	for group, metrics := range r.Metrics {
+		result[group] = metrics.Values()
+	}
+	return result
+}
+
 func (r runReport) resourcesMetrics() map[string]float64 {
 	return r.metricValues("resources")
 }
@@ -141,9 +232,142 @@ func (r runReport) reportTimeDurationMetrics() map[string]float64 {
 	return result
 }
 
+// failedResources returns the resources that failed during the run, sorted so
+// that a limit applied to them always keeps the same ones.
+func (r runReport) failedResources() []resourceRef {
+	var result []resourceRef
+	for key, status := range r.ResourceStatuses {
+		if status.Failed {
+			result = append(result, status.ref(key))
+		}
+	}
+	slices.SortFunc(result, compareResourceRefs)
+	return result
+}
+
+// fileFailures counts the failed resources declared in a manifest file.
+type fileFailures struct {
+	File   string
+	Failed float64
+}
+
+// failedFiles counts the failed resources by the manifest file declaring them,
+// most failures first. Resources Puppet does not know the file of, such as
+// generated ones, are left out.
+func (r runReport) failedFiles() []fileFailures {
+	counts := make(map[string]float64)
+	for _, status := range r.ResourceStatuses {
+		if status.Failed && status.File != "" {
+			counts[status.File]++
+		}
+	}
+
+	var result []fileFailures
+	for file, count := range counts {
+		result = append(result, fileFailures{File: file, Failed: count})
+	}
+	slices.SortFunc(result, func(a, b fileFailures) int {
+		if c := cmp.Compare(b.Failed, a.Failed); c != 0 {
+			return c
+		}
+		return strings.Compare(a.File, b.File)
+	})
+	return result
+}
+
+// resourceTimes returns the evaluation time of every resource, slowest first.
+func (r runReport) resourceTimes() []resourceTime {
+	result := make([]resourceTime, 0, len(r.ResourceStatuses))
+	for key, status := range r.ResourceStatuses {
+		result = append(result, resourceTime{Resource: status.ref(key), Seconds: status.EvaluationTime})
+	}
+	slices.SortFunc(result, func(a, b resourceTime) int {
+		if c := cmp.Compare(b.Seconds, a.Seconds); c != 0 {
+			return c
+		}
+		return compareResourceRefs(a.Resource, b.Resource)
+	})
+	return result
+}
+
+// resourceStates are the states resources are counted in per type. total counts
+// every resource of the type, whatever its state.
+var resourceStates = []string{"total", "failed", "changed", "out_of_sync", "skipped"}
+
+// resourcesByType counts the resources of each type in each of resourceStates.
+// Every state is present for every type, so that a type recovering from a
+// failure reports 0 rather than dropping the series.
+func (r runReport) resourcesByType() map[string]map[string]float64 {
+	result := make(map[string]map[string]float64)
+	for key, status := range r.ResourceStatuses {
+		resourceType := status.ref(key).Type
+		counts, ok := result[resourceType]
+		if !ok {
+			counts = make(map[string]float64, len(resourceStates))
+			for _, state := range resourceStates {
+				counts[state] = 0
+			}
+			result[resourceType] = counts
+		}
+		counts["total"]++
+		counts["failed"] += asFloat(status.Failed)
+		counts["changed"] += asFloat(status.Changed)
+		counts["out_of_sync"] += asFloat(status.OutOfSync)
+		counts["skipped"] += asFloat(status.Skipped)
+	}
+	return result
+}
+
 type resourceStatus struct {
-	Failed         bool    `yaml:"failed"`
-	EvaluationTime float64 `yaml:"evaluation_time"`
+	ResourceType    string          `yaml:"resource_type"`
+	Title           string          `yaml:"title"`
+	Failed          bool            `yaml:"failed"`
+	FailedToRestart bool            `yaml:"failed_to_restart"`
+	Changed         bool            `yaml:"changed"`
+	OutOfSync       bool            `yaml:"out_of_sync"`
+	Skipped         bool            `yaml:"skipped"`
+	EvaluationTime  float64         `yaml:"evaluation_time"`
+	File            string          `yaml:"file"`
+	ContainmentPath []string        `yaml:"containment_path"`
+	Events          []resourceEvent `yaml:"events"`
+}
+
+// ref identifies the resource. Puppet writes resource_type and title in every
+// status, but older reports only carry them in the Type[title] key.
+func (s resourceStatus) ref(key string) resourceRef {
+	if s.ResourceType != "" && s.Title != "" {
+		return resourceRef{Type: s.ResourceType, Title: s.Title}
+	}
+	return parseResourceRef(key)
+}
+
+// resourceRef identifies a Puppet resource by its type and title.
+type resourceRef struct {
+	Type  string
+	Title string
+}
+
+// parseResourceRef splits a Type[title] resource reference. A key that does not
+// have that shape is kept whole as the title.
+func parseResourceRef(key string) resourceRef {
+	open := strings.Index(key, "[")
+	if open <= 0 || !strings.HasSuffix(key, "]") {
+		return resourceRef{Title: key}
+	}
+	return resourceRef{Type: key[:open], Title: key[open+1 : len(key)-1]}
+}
+
+// resourceTime is the time Puppet spent evaluating a resource.
+type resourceTime struct {
+	Resource resourceRef
+	Seconds  float64
+}
+
+func compareResourceRefs(a, b resourceRef) int {
+	if c := strings.Compare(a.Type, b.Type); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Title, b.Title)
 }
 
 type puppetUtilMetric struct {
@@ -165,8 +389,20 @@ func (s puppetUtilMetric) Values() map[string]float64 {
 	return result
 }
 
+// logLevels counts the report log messages per level.
+func (r runReport) logLevels() map[string]float64 {
+	result := make(map[string]float64)
+	for _, log := range r.Logs {
+		result[log.Level]++
+	}
+	return result
+}
+
 type puppetUtilLog struct {
-	Time time.Time `yaml:"time"`
+	Level   string    `yaml:"level"`
+	Source  string    `yaml:"source"`
+	Message string    `yaml:"message"`
+	Time    time.Time `yaml:"time"`
 }
 
 func load(path string) (runReport, error) {
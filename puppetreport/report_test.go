@@ -27,10 +27,20 @@ func TestLoadReport(t *testing.T) {
 
 	ir := report.interpret()
 	expected := interpretedReport{
-		RunAt:          1618957125.5901103,
-		RunDuration:    17.199882286,
-		CatalogVersion: 1618957129,
-		RunSuccess:     1,
+		RunAt:               1618957125.5901103,
+		RunDuration:         17.199882286,
+		CatalogVersion:      1618957129,
+		RunSuccess:          1,
+		Status:              "changed",
+		CachedCatalogStatus: "not_used",
+		Info: reportInfo{
+			Host:            "naughty-1-001.redacted.internal",
+			Environment:     "production",
+			PuppetVersion:   "7.5.0",
+			ReportFormat:    "12",
+			CatalogUUID:     "3d2737d6-a109-4dfe-859e-2773307fc257",
+			TransactionUUID: "77d7a293-bbcd-498f-8fa7-5bab45f7d68c",
+		},
 		RunReportResources: map[string]float64{
 			"total":             574,
 			"skipped":           0,
@@ -58,6 +68,40 @@ func TestLoadReport(t *testing.T) {
 			"transaction_evaluation": 23.296303944662213,
 			"catalog_application":    23.389429319649935,
 		},
+		LogMessages: map[string]float64{
+			"notice": 1,
+		},
+		EventsByProperty: map[string]map[string]float64{},
+		MetricGroups: map[string]map[string]float64{
+			"resources": {
+				"total":             574,
+				"skipped":           0,
+				"failed":            0,
+				"failed_to_restart": 0,
+				"restarted":         0,
+				"changed":           1,
+				"out_of_sync":       1,
+				"scheduled":         0,
+				"corrective_change": 1,
+			},
+			"time": {
+				"total":                  17.199882286,
+				"plugin_sync":            19.038186447694898,
+				"fact_generation":        3.733549404889345,
+				"convert_catalog":        1.6039954144507647,
+				"config_retrieval":       7.887831624597311,
+				"transaction_evaluation": 23.296303944662213,
+				"catalog_application":    23.389429319649935,
+			},
+			"changes": {"total": 0},
+			"events":  {"total": 0, "failure": 0, "success": 0},
+		},
+		ResourcesByType: map[string]map[string]float64{
+			"File": {"total": 1, "failed": 0, "changed": 0, "out_of_sync": 0, "skipped": 0},
+		},
+		ResourceTimes: []resourceTime{
+			{Resource: resourceRef{Type: "File", Title: "/var/log/unattended-upgrades"}},
+		},
 	}
 
 	if !reflect.DeepEqual(ir, expected) {
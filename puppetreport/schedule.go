@@ -0,0 +1,50 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"time"
+
+	"github.com/fgouteroux/puppet-agent-exporter/puppetconfig"
+)
+
+// DefaultRunInterval is the runinterval Puppet uses when puppet.conf does not
+// set one.
+const DefaultRunInterval = 30 * time.Minute
+
+// nextRunDelay returns how long after the start of a run the agent daemon
+// starts the next one at the latest: runinterval, plus splaylimit when splay
+// is enabled. splaylimit defaults to runinterval, like in Puppet.
+func nextRunDelay(config *puppetconfig.Config) time.Duration {
+	interval := durationSetting(config, "runinterval", DefaultRunInterval)
+	if config.Setting("splay") != "true" {
+		return interval
+	}
+	return interval + durationSetting(config, "splaylimit", interval)
+}
+
+// durationSetting returns the value of a duration setting, or def when it is
+// not set or is not a valid duration.
+func durationSetting(config *puppetconfig.Config, key string, def time.Duration) time.Duration {
+	value := config.Setting(key)
+	if value == "" {
+		return def
+	}
+	duration, err := puppetconfig.ParseDuration(value)
+	if err != nil {
+		return def
+	}
+	return duration
+}
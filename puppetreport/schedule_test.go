@@ -0,0 +1,51 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fgouteroux/puppet-agent-exporter/puppetconfig"
+)
+
+func TestNextRunDelay(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		want   time.Duration
+	}{
+		{config: "[main]\n", want: 30 * time.Minute},
+		{config: "[agent]\nruninterval = 3600\n", want: time.Hour},
+		{config: "[agent]\nruninterval = 4h\nsplay = false\nsplaylimit = 1h\n", want: 4 * time.Hour},
+		// splaylimit defaults to runinterval.
+		{config: "[agent]\nruninterval = 1h\nsplay = true\n", want: 2 * time.Hour},
+		{config: "[agent]\nruninterval = 1h\nsplay = true\nsplaylimit = 5m\n", want: time.Hour + 5*time.Minute},
+		{config: "[agent]\nruninterval = often\n", want: 30 * time.Minute},
+	} {
+		path := filepath.Join(t.TempDir(), "puppet.conf")
+		if err := os.WriteFile(path, []byte(tc.config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := puppetconfig.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nextRunDelay(config); got != tc.want {
+			t.Errorf("nextRunDelay() with %q = %v, want %v", tc.config, got, tc.want)
+		}
+	}
+}
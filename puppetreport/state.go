@@ -0,0 +1,131 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// runState is what the exporter remembers of past runs. Puppet overwrites the
+// last run report on every run, so once a run fails the time of the last
+// successful one is only known from here.
+type runState struct {
+	LastRunAt           float64 `json:"last_run_at"`
+	LastSuccessAt       float64 `json:"last_success_at"`
+	ConsecutiveFailures float64 `json:"consecutive_failures"`
+}
+
+// lastSuccessAt returns the time of the last successful run, or NaN when none
+// was seen since the state was created.
+func (s runState) lastSuccessAt() float64 {
+	if s.LastSuccessAt == 0 {
+		return math.NaN()
+	}
+	return s.LastSuccessAt
+}
+
+// stateFile keeps the run state in memory and persists it to disk whenever a
+// new report is observed, so that it survives exporter restarts.
+type stateFile struct {
+	mu     sync.Mutex
+	path   string
+	state  runState
+	loaded bool
+}
+
+// update records report in the state persisted at path, unless it was already
+// recorded, and returns the resulting state. report may be nil when the last
+// run report could not be read, in which case the state is returned as is.
+// The state is returned even alongside an error, since it remains correct in
+// memory when it cannot be read from or written to disk.
+func (f *stateFile) update(path string, report *interpretedReport) (runState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var loadErr error
+	if !f.loaded || f.path != path {
+		// A corrupt file is not retried on every scrape: the state starts
+		// afresh, and the next recorded run overwrites it.
+		f.state, loadErr = readState(path)
+		f.path = path
+		f.loaded = true
+	}
+
+	// A report is recorded once, however many scrapes observe it. Comparing
+	// run times rather than relying on the report cache also keeps a restart
+	// from counting the same failed run twice.
+	if report == nil || report.RunAt <= f.state.LastRunAt {
+		return f.state, loadErr
+	}
+
+	f.state.LastRunAt = report.RunAt
+	if report.RunSuccess == 1 {
+		f.state.LastSuccessAt = report.RunAt
+		f.state.ConsecutiveFailures = 0
+	} else {
+		f.state.ConsecutiveFailures++
+	}
+
+	return f.state, errors.Join(loadErr, writeState(path, f.state))
+}
+
+func readState(path string) (runState, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return runState{}, nil
+		}
+		return runState{}, err
+	}
+
+	var state runState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return runState{}, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// writeState replaces the state file atomically, so that a crash mid-write
+// cannot leave a truncated file behind.
+func writeState(path string, state runState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		return errors.Join(err, tmp.Close(), os.Remove(tmp.Name()))
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Join(err, os.Remove(tmp.Name()))
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Join(err, os.Remove(tmp.Name()))
+	}
+	return nil
+}
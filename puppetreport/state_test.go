@@ -0,0 +1,98 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateSurvivesFailuresAndRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+
+	var state stateFile
+	runs := []interpretedReport{
+		{RunAt: 1000, RunSuccess: 1},
+		{RunAt: 2800, RunSuccess: 0},
+		{RunAt: 4600, RunSuccess: 0},
+	}
+	for _, run := range runs {
+		// Every scrape observes the report, the run must only count once.
+		for range 3 {
+			if _, err := state.update(path, &run); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A restarted exporter reads the state back, and the report it finds on
+	// disk is the one already recorded.
+	var restarted stateFile
+	got, err := restarted.update(path, &runs[len(runs)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastSuccessAt != 1000 {
+		t.Errorf("LastSuccessAt = %v, want 1000", got.LastSuccessAt)
+	}
+	if got.ConsecutiveFailures != 2 {
+		t.Errorf("ConsecutiveFailures = %v, want 2", got.ConsecutiveFailures)
+	}
+
+	got, err = restarted.update(path, &interpretedReport{RunAt: 6400, RunSuccess: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastSuccessAt != 6400 || got.ConsecutiveFailures != 0 {
+		t.Errorf("state = %+v after a successful run, want LastSuccessAt 6400 and no failures", got)
+	}
+}
+
+func TestStateWithoutReport(t *testing.T) {
+	var state stateFile
+	got, err := state.update(filepath.Join(t.TempDir(), "state.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(got.lastSuccessAt()) {
+		t.Errorf("lastSuccessAt() = %v, want NaN before any successful run", got.lastSuccessAt())
+	}
+}
+
+// A corrupt state file is reported, then replaced by the next recorded run.
+func TestStateCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var state stateFile
+	if _, err := state.update(path, nil); err == nil {
+		t.Fatal("expected an error for a corrupt state file")
+	}
+	if _, err := state.update(path, &interpretedReport{RunAt: 1000, RunSuccess: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastSuccessAt != 1000 {
+		t.Errorf("LastSuccessAt = %v, want 1000", got.LastSuccessAt)
+	}
+}
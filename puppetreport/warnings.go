@@ -0,0 +1,96 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxFingerprintLength bounds the length of a warning fingerprint, so that a
+// long message does not make for an unwieldy label value.
+const maxFingerprintLength = 200
+
+var (
+	// quotedValuePattern only takes a single quote for an opening one at the
+	// start of the message or after a space or punctuation, so that the
+	// apostrophe of a contraction such as can't does not open a value.
+	quotedValuePattern = regexp.MustCompile(`(^|[\s(\[:=,])('[^']*')|"[^"]*"|` + "`[^`]*`")
+	pathPattern        = regexp.MustCompile(`(^|[\s(=])(?:~|[A-Za-z]:)?[/\\][^\s'",;:()]+`)
+	numberPattern      = regexp.MustCompile(`\b\d+(?:\.\d+)*\b`)
+
+	// quotedIdentifierPattern matches the single-quoted names of functions,
+	// variables, classes and settings Puppet puts in its messages, such as
+	// 'hiera' or '::osfamily'.
+	quotedIdentifierPattern = regexp.MustCompile(`^'(?:::)?[A-Za-z_]\w*(?:::\w+)*'$`)
+)
+
+// warningFingerprint normalises a warning message so that the same warning
+// emitted for different resources, files or values gets the same fingerprint:
+// quoted values, paths and numbers are replaced by placeholders. Quoted
+// identifiers are kept, as they tell apart warnings such as the deprecation
+// of one function from that of another.
+func warningFingerprint(message string) string {
+	fingerprint := quotedValuePattern.ReplaceAllStringFunc(message, func(match string) string {
+		prefix, quoted := "", match
+		if submatch := quotedValuePattern.FindStringSubmatch(match); submatch[2] != "" {
+			prefix, quoted = submatch[1], submatch[2]
+		}
+		if quotedIdentifierPattern.MatchString(quoted) {
+			return match
+		}
+		return prefix + "<value>"
+	})
+	fingerprint = pathPattern.ReplaceAllString(fingerprint, "${1}<path>")
+	fingerprint = numberPattern.ReplaceAllString(fingerprint, "<n>")
+	fingerprint = strings.Join(strings.Fields(fingerprint), " ")
+	if len(fingerprint) > maxFingerprintLength {
+		fingerprint = strings.ToValidUTF8(fingerprint[:maxFingerprintLength], "")
+	}
+	return fingerprint
+}
+
+// warningFingerprintCount is the number of warning messages of the run with
+// a fingerprint.
+type warningFingerprintCount struct {
+	Fingerprint string
+	Count       float64
+}
+
+// warningMessages counts the warning log messages of the run by fingerprint.
+// The fingerprints are sorted by count, so that a limit applied to them keeps
+// the most frequent ones.
+func (r runReport) warningMessages() []warningFingerprintCount {
+	counts := make(map[string]float64)
+	for _, log := range r.Logs {
+		if log.Level == "warning" {
+			counts[warningFingerprint(log.Message)]++
+		}
+	}
+
+	var result []warningFingerprintCount
+	for fingerprint, count := range counts {
+		result = append(result, warningFingerprintCount{Fingerprint: fingerprint, Count: count})
+	}
+	slices.SortFunc(result, func(a, b warningFingerprintCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return result
+}
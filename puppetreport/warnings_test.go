@@ -0,0 +1,80 @@
+// Copyright 2021 RetailNext, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puppetreport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWarningFingerprint(t *testing.T) {
+	for _, tc := range []struct {
+		message string
+		want    string
+	}{
+		{
+			message: "The function 'hiera' is deprecated in favor of using 'lookup'. See https://puppet.com/docs/puppet/7/deprecated_language.html\n   (file: /etc/puppetlabs/code/environments/production/modules/profile/manifests/base.pp, line: 12, column: 9)",
+			want:    "The function 'hiera' is deprecated in favor of using 'lookup'. See https://puppet.com/docs/puppet/<n>/deprecated_language.html (file: <path>, line: <n>, column: <n>)",
+		},
+		{
+			message: `Unknown variable: "::osfamily" at C:\ProgramData\PuppetLabs\code\site.pp:3:5`,
+			want:    "Unknown variable: <value> at <path>:<n>:<n>",
+		},
+		{
+			message: "Scope(Class[Profile::Base]): retrying in 2.5 seconds",
+			want:    "Scope(Class[Profile::Base]): retrying in <n> seconds",
+		},
+		{
+			message: "Unknown variable: '::osfamily'. (file: /etc/puppetlabs/code/site.pp, line: 3)",
+			want:    "Unknown variable: '::osfamily'. (file: <path>, line: <n>)",
+		},
+		{
+			message: "Invalid value 'yes please' for parameter 'enable' of Service[nginx]",
+			want:    "Invalid value <value> for parameter 'enable' of Service[nginx]",
+		},
+		{
+			// The apostrophe of a contraction does not open a quoted value.
+			message: "Puppet can't find 'foo' for bar, it isn't 'set up'",
+			want:    "Puppet can't find 'foo' for bar, it isn't <value>",
+		},
+		{
+			message: "Could not read ~/.puppetlabs/etc/puppet/puppet.conf",
+			want:    "Could not read <path>",
+		},
+	} {
+		if got := warningFingerprint(tc.message); got != tc.want {
+			t.Errorf("warningFingerprint(%q) = %q, want %q", tc.message, got, tc.want)
+		}
+	}
+}
+
+func TestWarningMessages(t *testing.T) {
+	report := runReport{Logs: []puppetUtilLog{
+		{Level: "warning", Message: "Unknown variable: '::osfamily'. (file: /etc/puppetlabs/code/site.pp, line: 3)"},
+		{Level: "warning", Message: "Unknown variable: '::osfamily'. (file: /etc/puppetlabs/code/base.pp, line: 8)"},
+		{Level: "warning", Message: "Unknown variable: '::lsbdistcodename'. (file: /etc/puppetlabs/code/site.pp, line: 8)"},
+		{Level: "warning", Message: "Skipping because of failed dependencies"},
+		{Level: "notice", Message: "Applied catalog in 1.23 seconds"},
+	}}
+
+	want := []warningFingerprintCount{
+		{Fingerprint: "Unknown variable: '::osfamily'. (file: <path>, line: <n>)", Count: 2},
+		{Fingerprint: "Skipping because of failed dependencies", Count: 1},
+		{Fingerprint: "Unknown variable: '::lsbdistcodename'. (file: <path>, line: <n>)", Count: 1},
+	}
+	if got := report.warningMessages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("warningMessages() = %+v, want %+v", got, want)
+	}
+}